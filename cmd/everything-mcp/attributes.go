@@ -0,0 +1,60 @@
+package main
+
+import "strings"
+
+// FileAttributes Windows 文件属性（FILE_ATTRIBUTE_*），由 Everything 的 attributes 列返回
+// 0 表示未知（没有请求该列，或后端不提供属性）
+type FileAttributes uint32
+
+// 常用的 Windows 文件属性位
+const (
+	AttributeReadOnly     FileAttributes = 0x1
+	AttributeHidden       FileAttributes = 0x2
+	AttributeSystem       FileAttributes = 0x4
+	AttributeDirectory    FileAttributes = 0x10
+	AttributeArchive      FileAttributes = 0x20
+	AttributeNormal       FileAttributes = 0x80
+	AttributeReparsePoint FileAttributes = 0x400
+	AttributeCompressed   FileAttributes = 0x800
+	AttributeEncrypted    FileAttributes = 0x4000
+)
+
+// ReadOnly 只读
+func (a FileAttributes) ReadOnly() bool { return a&AttributeReadOnly != 0 }
+
+// Hidden 隐藏
+func (a FileAttributes) Hidden() bool { return a&AttributeHidden != 0 }
+
+// System 系统文件
+func (a FileAttributes) System() bool { return a&AttributeSystem != 0 }
+
+// ReparsePoint 重解析点（符号链接、目录联接等）
+func (a FileAttributes) ReparsePoint() bool { return a&AttributeReparsePoint != 0 }
+
+// attributeNames 显示用的属性名称，按 Windows 资源管理器的习惯排列
+var attributeNames = []struct {
+	flag FileAttributes
+	name string
+}{
+	{AttributeReadOnly, "只读"},
+	{AttributeHidden, "隐藏"},
+	{AttributeSystem, "系统"},
+	{AttributeArchive, "存档"},
+	{AttributeCompressed, "压缩"},
+	{AttributeEncrypted, "加密"},
+	{AttributeReparsePoint, "重解析点"},
+}
+
+// String 返回属性的文本表示，例如 "只读, 隐藏"；没有特殊属性时返回 "无"
+func (a FileAttributes) String() string {
+	var names []string
+	for _, n := range attributeNames {
+		if a&n.flag != 0 {
+			names = append(names, n.name)
+		}
+	}
+	if len(names) == 0 {
+		return "无"
+	}
+	return strings.Join(names, ", ")
+}
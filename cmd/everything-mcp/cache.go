@@ -0,0 +1,246 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultCacheMaxBytes 缓存默认的最大内存占用
+const defaultCacheMaxBytes = 64 << 20
+
+// CacheStats 缓存的命中统计
+type CacheStats struct {
+	Hits      int64  `json:"hits"`
+	Misses    int64  `json:"misses"`
+	Coalesced int64  `json:"coalesced"` // 合并到进行中请求的次数（不产生新的后端调用）
+	Evictions int64  `json:"evictions"`
+	Entries   int    `json:"entries"`
+	Bytes     int64  `json:"bytes"`
+	MaxBytes  int64  `json:"max_bytes"`
+	TTL       string `json:"ttl"`
+}
+
+// cacheEntry 一条缓存的搜索结果
+type cacheEntry struct {
+	key      string
+	response *SearchResponse
+	size     int64
+	expires  time.Time
+}
+
+// cacheCall 一次进行中的后端调用，相同参数的并发请求共享它的结果
+type cacheCall struct {
+	done     chan struct{}
+	response *SearchResponse
+	err      error
+
+	cancel  context.CancelFunc
+	waiters int // 仍在等待结果的请求数，降为 0 时取消后端调用
+}
+
+// CachingSearcher 为 EverythingSearcher 增加结果缓存的装饰器
+// 缓存条目在 TTL 后过期，总内存超过上限时按最近最少使用淘汰；
+// 相同参数的并发请求合并为一次后端调用
+type CachingSearcher struct {
+	next     EverythingSearcher
+	ttl      time.Duration
+	maxBytes int64
+
+	mu       sync.Mutex
+	entries  map[string]*list.Element // 值为 *cacheEntry
+	lru      *list.List               // 最近使用的在前
+	bytes    int64
+	inflight map[string]*cacheCall
+	stats    CacheStats
+}
+
+// NewCachingSearcher 创建缓存装饰器，maxBytes <= 0 时使用默认上限
+func NewCachingSearcher(next EverythingSearcher, ttl time.Duration, maxBytes int64) *CachingSearcher {
+	if maxBytes <= 0 {
+		maxBytes = defaultCacheMaxBytes
+	}
+	return &CachingSearcher{
+		next:     next,
+		ttl:      ttl,
+		maxBytes: maxBytes,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+		inflight: make(map[string]*cacheCall),
+	}
+}
+
+// cacheKey 由全部搜索参数（包括分页位置和结果列）生成缓存键
+func cacheKey(opts SearchOptions) string {
+	return fmt.Sprintf("%s\x00%d\x00%d\x00%s\x00%t%t%t%t%t\x00%s",
+		opts.Query, opts.Offset, opts.MaxResults, opts.Sort,
+		opts.MatchCase, opts.MatchWholeWord, opts.MatchPath, opts.MatchDiacritics, opts.Regex,
+		strings.Join(opts.Columns, ","))
+}
+
+// responseSize 估算搜索结果占用的内存字节数
+func responseSize(key string, response *SearchResponse) int64 {
+	size := int64(len(key)) + 128
+	for _, r := range response.Results {
+		size += int64(len(r.Path)+len(r.FullPath)+len(r.Type)+len(r.Source)) + 192
+	}
+	return size
+}
+
+// copyResponse 返回结果的副本，避免调用方修改缓存中的数据（例如对结果排序）
+func copyResponse(response *SearchResponse) *SearchResponse {
+	c := *response
+	c.Results = append([]SearchResult(nil), response.Results...)
+	c.Warnings = append([]string(nil), response.Warnings...)
+	return &c
+}
+
+// Search 优先返回缓存的结果；未命中时调用后端，相同参数的并发请求只调用一次
+func (c *CachingSearcher) Search(ctx context.Context, opts SearchOptions) (*SearchResponse, error) {
+	key := cacheKey(opts)
+
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*cacheEntry)
+		if time.Now().Before(entry.expires) {
+			c.lru.MoveToFront(elem)
+			c.stats.Hits++
+			c.mu.Unlock()
+			return copyResponse(entry.response), nil
+		}
+		c.remove(elem)
+	}
+
+	call, ok := c.inflight[key]
+	if ok {
+		c.stats.Coalesced++
+	} else {
+		c.stats.Misses++
+		// 后端调用不跟随单个请求的 context：只有所有等待者都离开后才取消
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &cacheCall{done: make(chan struct{}), cancel: cancel}
+		c.inflight[key] = call
+		go c.do(callCtx, key, opts, call)
+	}
+	call.waiters++
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+		if call.err != nil {
+			return nil, call.err
+		}
+		return copyResponse(call.response), nil
+	case <-ctx.Done():
+		c.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			// 没有人再等待：取消后端调用，之后的相同请求重新发起
+			call.cancel()
+			if c.inflight[key] == call {
+				delete(c.inflight, key)
+			}
+		}
+		c.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// do 执行后端调用并缓存成功的完整结果（带警告的部分结果不缓存）
+func (c *CachingSearcher) do(ctx context.Context, key string, opts SearchOptions, call *cacheCall) {
+	defer call.cancel()
+	response, err := c.next.Search(ctx, opts)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.inflight[key] == call {
+		delete(c.inflight, key)
+	}
+	call.response, call.err = response, err
+	close(call.done)
+
+	if err != nil || len(response.Warnings) > 0 {
+		return
+	}
+	entry := &cacheEntry{
+		key:      key,
+		response: copyResponse(response),
+		size:     responseSize(key, response),
+		expires:  time.Now().Add(c.ttl),
+	}
+	if entry.size > c.maxBytes {
+		return
+	}
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	c.bytes += entry.size
+	for c.bytes > c.maxBytes {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+// remove 删除一条缓存，调用方需持有锁
+func (c *CachingSearcher) remove(elem *list.Element) {
+	entry := elem.Value.(*cacheEntry)
+	c.lru.Remove(elem)
+	delete(c.entries, entry.key)
+	c.bytes -= entry.size
+}
+
+// Stats 返回当前的缓存统计
+func (c *CachingSearcher) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = len(c.entries)
+	stats.Bytes = c.bytes
+	stats.MaxBytes = c.maxBytes
+	stats.TTL = c.ttl.String()
+	return stats
+}
+
+// cacheStatsSchema cache_stats 的 outputSchema
+var cacheStatsSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"hits": map[string]interface{}{
+			"type":        "integer",
+			"description": "直接从缓存返回的次数",
+		},
+		"misses": map[string]interface{}{
+			"type":        "integer",
+			"description": "调用后端的次数",
+		},
+		"coalesced": map[string]interface{}{
+			"type":        "integer",
+			"description": "合并到进行中的相同请求、没有产生新后端调用的次数",
+		},
+		"evictions": map[string]interface{}{
+			"type":        "integer",
+			"description": "因超出内存上限被淘汰的条目数",
+		},
+		"entries": map[string]interface{}{
+			"type":        "integer",
+			"description": "当前缓存的条目数",
+		},
+		"bytes": map[string]interface{}{
+			"type":        "integer",
+			"description": "当前缓存占用的估算字节数",
+		},
+		"max_bytes": map[string]interface{}{
+			"type":        "integer",
+			"description": "缓存的内存上限（字节）",
+		},
+		"ttl": map[string]interface{}{
+			"type":        "string",
+			"description": "缓存条目的有效期，例如 30s",
+		},
+	},
+	"required": []string{"hits", "misses", "coalesced", "evictions", "entries", "bytes", "max_bytes", "ttl"},
+}
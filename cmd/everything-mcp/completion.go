@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// maxCompletionValues completion/complete 最多返回的候选值数量（MCP 规定不超过 100）
+const maxCompletionValues = 100
+
+// completionSearchLimit 补全路径时在父文件夹中最多读取的子项数量
+const completionSearchLimit = 1000
+
+// InitializeResult initialize 的结果，在 mcp.InitializeResult 的基础上增加 completions capability
+type InitializeResult struct {
+	mcp.InitializeResult
+	Capabilities ServerCapabilities `json:"capabilities"`
+}
+
+// ServerCapabilities 服务器支持的功能，在 mcp.ServerCapabilities 的基础上增加 completions
+type ServerCapabilities struct {
+	mcp.ServerCapabilities
+	Completions *struct{} `json:"completions,omitempty"`
+}
+
+// withCompletions 在 mcp-go 生成的 initialize 结果中声明 completions capability
+func withCompletions(result interface{}) interface{} {
+	r, ok := result.(*mcp.InitializeResult)
+	if !ok {
+		return result
+	}
+	return &InitializeResult{
+		InitializeResult: *r,
+		Capabilities: ServerCapabilities{
+			ServerCapabilities: r.Capabilities,
+			Completions:        &struct{}{},
+		},
+	}
+}
+
+// CompletionReference completion/complete 的 ref 参数
+// 除了 MCP 规定的 ref/prompt 和 ref/resource，还支持 ref/tool，用于补全工具的参数
+type CompletionReference struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+	URI  string `json:"uri,omitempty"`
+}
+
+// folderPathTools path 参数只接受文件夹的工具
+var folderPathTools = map[string]bool{
+	"search_by_path":     true,
+	"search_large_files": true,
+	"search_empty_files": true,
+	"search_with_regex":  true,
+	"list_directory":     true,
+}
+
+// handleComplete 补全工具、提示词或资源模板的参数
+func (s *MCPEverythingServer) handleComplete(ctx context.Context, ref CompletionReference, arg mcp.CompleteArgument) (*mcp.CompleteResult, error) {
+	var values []string
+	var hasMore bool
+	var err error
+
+	switch ref.Type {
+	case "ref/tool":
+		if !isKnownTool(ref.Name) || !s.tools[ref.Name] {
+			return nil, invalidParams("未知的工具: %s", ref.Name)
+		}
+		switch {
+		case arg.Name == "path" && folderPathTools[ref.Name]:
+			values, hasMore, err = s.completePath(ctx, arg.Value, true)
+		case arg.Name == "path" && ref.Name == "get_file_info":
+			values, hasMore, err = s.completePath(ctx, arg.Value, false)
+		case arg.Name == "extension" && ref.Name == "search_by_extension":
+			values = completeExtension(arg.Value)
+		case arg.Name == "content_type" && ref.Name == "search_by_content_type":
+			values = completeContentType(arg.Value)
+		}
+
+	case "ref/prompt":
+		var prompt *PromptDefinition
+		for i := range s.prompts {
+			if s.prompts[i].Name == ref.Name && s.promptEnabled(s.prompts[i]) {
+				prompt = &s.prompts[i]
+			}
+		}
+		if prompt == nil {
+			return nil, invalidParams("未知的提示词: %s", ref.Name)
+		}
+		// 提示词中名为 path 的参数都是文件夹
+		if arg.Name == "path" {
+			values, hasMore, err = s.completePath(ctx, arg.Value, true)
+		}
+
+	case "ref/resource":
+		// 资源对应的工具被禁用时不补全
+		pathTools := s.tools[folderResourceTool] || s.tools[fileResourceTool]
+		switch {
+		case ref.URI == resourceTemplates[0].URITemplate && !pathTools,
+			ref.URI == resourceTemplates[1].URITemplate && !s.tools[searchResourceTool]:
+			return nil, invalidParams("资源模板对应的工具已被禁用: %s", ref.URI)
+		case ref.URI == resourceTemplates[0].URITemplate && arg.Name == "host":
+			values = filterPrefix(s.resourceHosts(), arg.Value)
+		case ref.URI == resourceTemplates[0].URITemplate && arg.Name == "path":
+			values, hasMore, err = s.completeResourcePath(ctx, arg.Value)
+		case ref.URI == resourceTemplates[1].URITemplate && arg.Name == "sort":
+			values = filterPrefix(sortFields, arg.Value)
+		case ref.URI == resourceTemplates[1].URITemplate && arg.Name == "order":
+			values = filterPrefix([]string{"asc", "desc"}, arg.Value)
+		}
+
+	default:
+		return nil, invalidParams("不支持的 ref 类型: %s（可选: ref/tool, ref/prompt, ref/resource）", ref.Type)
+	}
+	if err != nil {
+		return nil, newRPCError(codeInternalError, "补全失败: %v", err)
+	}
+
+	result := &mcp.CompleteResult{Completion: mcp.Completion{Values: []string{}}}
+	result.Completion.Total = len(values)
+	result.Completion.HasMore = hasMore || len(values) > maxCompletionValues
+	if len(values) > maxCompletionValues {
+		values = values[:maxCompletionValues]
+	}
+	if values != nil {
+		result.Completion.Values = values
+	}
+	return result, nil
+}
+
+// completeExtension 补全扩展名，候选值来自 contentTypeExtensions
+func completeExtension(value string) []string {
+	dot := ""
+	if strings.HasPrefix(value, ".") {
+		dot = "."
+		value = value[1:]
+	}
+	seen := make(map[string]bool)
+	var extensions []string
+	for _, exts := range contentTypeExtensions {
+		for _, ext := range exts {
+			if !seen[ext] {
+				seen[ext] = true
+				extensions = append(extensions, ext)
+			}
+		}
+	}
+	sort.Strings(extensions)
+
+	values := filterPrefix(extensions, value)
+	for i := range values {
+		values[i] = dot + values[i]
+	}
+	return values
+}
+
+// completeContentType 补全内容类型，候选值为 contentTypeExtensions 中的类型
+func completeContentType(value string) []string {
+	types := make([]string, 0, len(contentTypeExtensions))
+	for t := range contentTypeExtensions {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return filterPrefix(types, value)
+}
+
+// filterPrefix 返回以 prefix 开头的候选值（不区分大小写）
+func filterPrefix(candidates []string, prefix string) []string {
+	var values []string
+	for _, c := range candidates {
+		if hasPrefixFold(c, prefix) {
+			values = append(values, c)
+		}
+	}
+	return values
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// completePath 补全路径：在父文件夹中查找以已输入的名称开头的子项（parent: 查询），
+// 文件夹以路径分隔符结尾；还没有输入分隔符或父文件夹中没有匹配时，补全驱动器或根目录
+// foldersOnly 为 true 时只补全文件夹
+func (s *MCPEverythingServer) completePath(ctx context.Context, value string, foldersOnly bool) ([]string, bool, error) {
+	var values []string
+	var hasMore bool
+
+	if i := strings.LastIndexAny(value, `\/`); i >= 0 {
+		dir, prefix := value[:i+1], value[i+1:]
+		sep := value[i : i+1]
+
+		terms := []Term{Parent(dir), Text(prefix)}
+		if foldersOnly {
+			terms = append(terms, Folders())
+		}
+		query, err := NewQuery(terms...).Build()
+		if err != nil {
+			return nil, false, err
+		}
+		response, err := s.client.Search(ctx, SearchOptions{
+			Query:      query,
+			MaxResults: completionSearchLimit,
+			Sort:       SearchSort{Field: "name", Ascending: true},
+		})
+		if err != nil {
+			return nil, false, err
+		}
+		hasMore = response.TotalResults > len(response.Results)+response.Redacted
+
+		for _, result := range response.Results {
+			name := result.Path
+			if j := strings.LastIndexAny(name, `\/`); j >= 0 {
+				name = name[j+1:]
+			}
+			if !hasPrefixFold(name, prefix) {
+				continue
+			}
+			if result.Type == "folder" {
+				name += sep
+			}
+			values = append(values, dir+name)
+		}
+		if len(values) > 0 {
+			return values, hasMore, nil
+		}
+	}
+
+	roots, err := s.completionRoots(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	return filterPrefix(roots, value), false, nil
+}
+
+// completionRoots 返回路径补全的起点：配置了 allowed_roots 时为允许访问的根目录，否则为驱动器
+func (s *MCPEverythingServer) completionRoots(ctx context.Context) ([]string, error) {
+	if roots := s.policy.Roots(); len(roots) > 0 {
+		// 根目录是文件夹，与其他文件夹一样以分隔符结尾
+		values := make([]string, 0, len(roots))
+		for _, root := range roots {
+			if !strings.HasSuffix(root, `\`) && !strings.HasSuffix(root, "/") {
+				if strings.Contains(root, `\`) {
+					root += `\`
+				} else {
+					root += "/"
+				}
+			}
+			values = append(values, root)
+		}
+		return values, nil
+	}
+	response, err := s.client.Search(ctx, SearchOptions{Query: NewQuery(Roots()).String(), MaxResults: 100})
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var roots []string
+	for _, result := range response.Results {
+		root := driveRoot(result.Path)
+		if isDrivePath(result.Path) && !seen[root] {
+			seen[root] = true
+			roots = append(roots, root)
+		}
+	}
+	return roots, nil
+}
+
+// completeResourcePath 补全资源模板 everything://{host}/{+path} 中的 path
+// path 使用 / 分隔且没有开头的 /（C:/Users、tmp/logs），补全前转换为文件系统路径，补全后再转换回来
+// 单个字母可能是驱动器也可能是 POSIX 路径的开头，先按驱动器补全，没有结果时再按 POSIX 路径补全
+func (s *MCPEverythingServer) completeResourcePath(ctx context.Context, value string) ([]string, bool, error) {
+	var attempts []string
+	switch {
+	case value == "":
+		attempts = []string{""}
+	case strings.HasPrefix(value, "/"):
+		// //server/share -> \\server\share
+		attempts = []string{strings.ReplaceAll(value, "/", `\`)}
+	case isASCIILetter(value[0]) && (len(value) == 1 || value[1] == ':'):
+		attempts = []string{strings.ReplaceAll(value, "/", `\`), "/" + value}
+	default:
+		attempts = []string{"/" + value}
+	}
+
+	for _, path := range attempts {
+		paths, hasMore, err := s.completePath(ctx, path, false)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(paths) == 0 {
+			continue
+		}
+		values := make([]string, 0, len(paths))
+		for _, p := range paths {
+			isDir := strings.HasSuffix(p, `\`) || strings.HasSuffix(p, "/")
+			values = append(values, strings.TrimPrefix(resourceURIPath(p, isDir), "/"))
+		}
+		return values, hasMore, nil
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,648 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// configFileNames 按顺序在配置目录中查找的文件名
+var configFileNames = []string{"config.yaml", "config.yml", "config.toml", "config.json"}
+
+// maskedSecret --print-config 中代替密码显示的文本
+const maskedSecret = "********"
+
+// configFile 配置文件的内容：顶层是所有 profile 共用的设置，profiles 中的同名设置覆盖顶层
+//
+//	timeout: 5s
+//	profile: home
+//	profiles:
+//	  home:
+//	    base_url: http://localhost
+//	  work:
+//	    backends:
+//	      - name: ws1
+//	        url: http://10.0.0.5:8080
+type configFile struct {
+	// Profile 未通过 --profile 指定时使用的 profile
+	Profile  string                   `json:"profile,omitempty" yaml:"profile,omitempty" toml:"profile,omitempty"`
+	Profiles map[string]profileConfig `json:"profiles,omitempty" yaml:"profiles,omitempty" toml:"profiles,omitempty"`
+
+	profileConfig `yaml:",inline"`
+}
+
+// profileConfig 一组配置项，未设置的字段（nil）保持原值
+type profileConfig struct {
+	BaseURL  *string   `json:"base_url,omitempty" yaml:"base_url,omitempty" toml:"base_url,omitempty"`
+	Port     *int      `json:"port,omitempty" yaml:"port,omitempty" toml:"port,omitempty"`
+	Username *string   `json:"username,omitempty" yaml:"username,omitempty" toml:"username,omitempty"`
+	Password *string   `json:"password,omitempty" yaml:"password,omitempty" toml:"password,omitempty"`
+	Timeout  *duration `json:"timeout,omitempty" yaml:"timeout,omitempty" toml:"timeout,omitempty"`
+
+	Backend     *string             `json:"backend,omitempty" yaml:"backend,omitempty" toml:"backend,omitempty"`
+	LocalRoots  []string            `json:"local_roots,omitempty" yaml:"local_roots,omitempty" toml:"local_roots,omitempty"`
+	LocalRescan *duration           `json:"local_rescan,omitempty" yaml:"local_rescan,omitempty" toml:"local_rescan,omitempty"`
+	Backends    []backendFileConfig `json:"backends,omitempty" yaml:"backends,omitempty" toml:"backends,omitempty"`
+
+	MaxInFlight      *int      `json:"max_in_flight,omitempty" yaml:"max_in_flight,omitempty" toml:"max_in_flight,omitempty"`
+	CacheTTL         *duration `json:"cache_ttl,omitempty" yaml:"cache_ttl,omitempty" toml:"cache_ttl,omitempty"`
+	CacheMaxMB       *int      `json:"cache_max_mb,omitempty" yaml:"cache_max_mb,omitempty" toml:"cache_max_mb,omitempty"`
+	Retries          *int      `json:"retries,omitempty" yaml:"retries,omitempty" toml:"retries,omitempty"`
+	RetryBackoff     *duration `json:"retry_backoff,omitempty" yaml:"retry_backoff,omitempty" toml:"retry_backoff,omitempty"`
+	BreakerThreshold *int      `json:"breaker_threshold,omitempty" yaml:"breaker_threshold,omitempty" toml:"breaker_threshold,omitempty"`
+	BreakerProbe     *duration `json:"breaker_probe,omitempty" yaml:"breaker_probe,omitempty" toml:"breaker_probe,omitempty"`
+	Timezone         *string   `json:"timezone,omitempty" yaml:"timezone,omitempty" toml:"timezone,omitempty"`
+
+	// 工具默认值和上限
+	MaxResults      *int `json:"max_results,omitempty" yaml:"max_results,omitempty" toml:"max_results,omitempty"`
+	MaxResultsLimit *int `json:"max_results_limit,omitempty" yaml:"max_results_limit,omitempty" toml:"max_results_limit,omitempty"`
+
+	// 启用和禁用的工具（名称或分组），以及按工具覆盖的 max_results
+	Tools         []string                    `json:"tools,omitempty" yaml:"tools,omitempty" toml:"tools,omitempty"`
+	DisabledTools []string                    `json:"disabled_tools,omitempty" yaml:"disabled_tools,omitempty" toml:"disabled_tools,omitempty"`
+	ToolLimits    map[string]toolLimitsConfig `json:"tool_limits,omitempty" yaml:"tool_limits,omitempty" toml:"tool_limits,omitempty"`
+
+	// 路径访问策略
+	AllowedRoots []string `json:"allowed_roots,omitempty" yaml:"allowed_roots,omitempty" toml:"allowed_roots,omitempty"`
+	DenyGlobs    []string `json:"deny_globs,omitempty" yaml:"deny_globs,omitempty" toml:"deny_globs,omitempty"`
+
+	// 订阅的搜索结果资源的轮询间隔
+	ResourcePollInterval *duration `json:"resource_poll_interval,omitempty" yaml:"resource_poll_interval,omitempty" toml:"resource_poll_interval,omitempty"`
+
+	// 自定义提示词，只能在配置文件中设置
+	Prompts []promptFileConfig `json:"prompts,omitempty" yaml:"prompts,omitempty" toml:"prompts,omitempty"`
+}
+
+// toolLimitsConfig 配置文件中单个工具的 max_results 设置
+type toolLimitsConfig struct {
+	MaxResults      *int `json:"max_results,omitempty" yaml:"max_results,omitempty" toml:"max_results,omitempty"`
+	MaxResultsLimit *int `json:"max_results_limit,omitempty" yaml:"max_results_limit,omitempty" toml:"max_results_limit,omitempty"`
+}
+
+// promptFileConfig 配置文件中的一个提示词
+type promptFileConfig struct {
+	Name        string                     `json:"name" yaml:"name" toml:"name"`
+	Description string                     `json:"description,omitempty" yaml:"description,omitempty" toml:"description,omitempty"`
+	Arguments   []promptArgumentFileConfig `json:"arguments,omitempty" yaml:"arguments,omitempty" toml:"arguments,omitempty"`
+	Template    string                     `json:"template" yaml:"template" toml:"template"`
+	Tools       []string                   `json:"tools,omitempty" yaml:"tools,omitempty" toml:"tools,omitempty"`
+}
+
+// promptArgumentFileConfig 配置文件中提示词的一个参数
+type promptArgumentFileConfig struct {
+	Name        string `json:"name" yaml:"name" toml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty" toml:"description,omitempty"`
+	Required    bool   `json:"required,omitempty" yaml:"required,omitempty" toml:"required,omitempty"`
+	Default     string `json:"default,omitempty" yaml:"default,omitempty" toml:"default,omitempty"`
+}
+
+// backendFileConfig 配置文件中联合搜索的一个后端
+// 设置了 local_roots 的是本地后端，否则是 url 指向的 Everything 实例
+type backendFileConfig struct {
+	Name       string    `json:"name" yaml:"name" toml:"name"`
+	URL        string    `json:"url,omitempty" yaml:"url,omitempty" toml:"url,omitempty"`
+	Username   string    `json:"username,omitempty" yaml:"username,omitempty" toml:"username,omitempty"`
+	Password   string    `json:"password,omitempty" yaml:"password,omitempty" toml:"password,omitempty"`
+	Timeout    *duration `json:"timeout,omitempty" yaml:"timeout,omitempty" toml:"timeout,omitempty"`
+	LocalRoots []string  `json:"local_roots,omitempty" yaml:"local_roots,omitempty" toml:"local_roots,omitempty"`
+}
+
+// duration 配置文件中的时间间隔，写作 "10s"、"1m30s" 等
+type duration time.Duration
+
+// UnmarshalText 解析 time.ParseDuration 格式的时间间隔
+func (d *duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+// MarshalText 输出 time.Duration 的文本格式
+func (d duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// findConfigFile 在 XDG 配置目录中查找配置文件：
+// 先查找 $XDG_CONFIG_HOME/everything-mcp（默认 ~/.config，Windows 上为 %AppData%），
+// 再依次查找 $XDG_CONFIG_DIRS 中的 everything-mcp（默认 /etc/xdg）；都没有时返回空字符串
+func findConfigFile() string {
+	var dirs []string
+	if dir, err := os.UserConfigDir(); err == nil {
+		dirs = append(dirs, dir)
+	}
+	if runtime.GOOS != "windows" {
+		systemDirs := os.Getenv("XDG_CONFIG_DIRS")
+		if systemDirs == "" {
+			systemDirs = "/etc/xdg"
+		}
+		dirs = append(dirs, filepath.SplitList(systemDirs)...)
+	}
+
+	for _, dir := range dirs {
+		for _, name := range configFileNames {
+			path := filepath.Join(dir, "everything-mcp", name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// loadConfigFile 按扩展名解析 YAML、TOML 或 JSON 配置文件，未知的配置项视为错误
+func loadConfigFile(path string) (*configFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+
+	file := &configFile{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(file); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("解析配置文件 %s 失败: %w", path, err)
+		}
+	case ".toml":
+		meta, err := toml.Decode(string(data), file)
+		if err != nil {
+			return nil, fmt.Errorf("解析配置文件 %s 失败: %w", path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return nil, fmt.Errorf("解析配置文件 %s 失败: 未知的配置项 %s", path, undecoded[0])
+		}
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(file); err != nil {
+			return nil, fmt.Errorf("解析配置文件 %s 失败: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("不支持的配置文件格式: %s（可选: .yaml, .yml, .toml, .json）", path)
+	}
+	return file, nil
+}
+
+// apply 将已设置的配置项写入 config
+func (p *profileConfig) apply(config *EverythingConfig) error {
+	if p.BaseURL != nil {
+		config.BaseURL = *p.BaseURL
+	}
+	if p.Port != nil {
+		config.Port = *p.Port
+	}
+	if p.Username != nil {
+		config.Username = *p.Username
+	}
+	if p.Password != nil {
+		config.Password = *p.Password
+	}
+	if p.Timeout != nil {
+		config.Timeout = time.Duration(*p.Timeout)
+	}
+	if p.Backend != nil {
+		config.Backend = *p.Backend
+	}
+	if p.LocalRoots != nil {
+		config.LocalRoots = p.LocalRoots
+	}
+	if p.LocalRescan != nil {
+		config.LocalRescan = time.Duration(*p.LocalRescan)
+	}
+	if p.Backends != nil {
+		backends := make([]BackendConfig, 0, len(p.Backends))
+		seen := make(map[string]bool)
+		for _, b := range p.Backends {
+			backend, err := b.backendConfig()
+			if err != nil {
+				return err
+			}
+			if seen[backend.Name] {
+				return fmt.Errorf("后端名称重复: %s", backend.Name)
+			}
+			seen[backend.Name] = true
+			backends = append(backends, backend)
+		}
+		config.Backends = backends
+	}
+	if p.MaxInFlight != nil {
+		config.MaxInFlight = *p.MaxInFlight
+	}
+	if p.CacheTTL != nil {
+		config.CacheTTL = time.Duration(*p.CacheTTL)
+	}
+	if p.CacheMaxMB != nil {
+		config.CacheMaxBytes = int64(*p.CacheMaxMB) << 20
+	}
+	if p.Retries != nil {
+		config.Retries = *p.Retries
+	}
+	if p.RetryBackoff != nil {
+		config.RetryBackoff = time.Duration(*p.RetryBackoff)
+	}
+	if p.BreakerThreshold != nil {
+		config.BreakerThreshold = *p.BreakerThreshold
+	}
+	if p.BreakerProbe != nil {
+		config.BreakerProbe = time.Duration(*p.BreakerProbe)
+	}
+	if p.Timezone != nil {
+		config.DisplayTimezone = *p.Timezone
+	}
+	if p.MaxResults != nil {
+		config.MaxResults = *p.MaxResults
+	}
+	if p.MaxResultsLimit != nil {
+		config.MaxResultsLimit = *p.MaxResultsLimit
+	}
+	if p.Tools != nil {
+		config.Tools = p.Tools
+	}
+	if p.DisabledTools != nil {
+		config.DisabledTools = p.DisabledTools
+	}
+	// profile 中的 tool_limits 按工具合并，只覆盖设置了的项
+	if len(p.ToolLimits) > 0 {
+		limits := make(map[string]ToolLimits, len(config.ToolLimits)+len(p.ToolLimits))
+		for name, l := range config.ToolLimits {
+			limits[name] = l
+		}
+		for name, l := range p.ToolLimits {
+			merged := limits[name]
+			if l.MaxResults != nil {
+				merged.MaxResults = *l.MaxResults
+			}
+			if l.MaxResultsLimit != nil {
+				merged.MaxResultsLimit = *l.MaxResultsLimit
+			}
+			limits[name] = merged
+		}
+		config.ToolLimits = limits
+	}
+	if p.AllowedRoots != nil {
+		config.AllowedRoots = p.AllowedRoots
+	}
+	// deny_globs 替换默认规则；写成 [] 可以关闭默认规则
+	if p.DenyGlobs != nil {
+		config.DenyGlobs = p.DenyGlobs
+	}
+	if p.ResourcePollInterval != nil {
+		config.ResourcePollInterval = time.Duration(*p.ResourcePollInterval)
+	}
+	if p.Prompts != nil {
+		prompts := make([]PromptDefinition, 0, len(p.Prompts))
+		for _, prompt := range p.Prompts {
+			prompts = append(prompts, prompt.promptDefinition())
+		}
+		config.Prompts = prompts
+	}
+	return nil
+}
+
+// promptDefinition 转换为 PromptDefinition，内容在创建服务器时由 validatePrompt 检查
+func (p promptFileConfig) promptDefinition() PromptDefinition {
+	prompt := PromptDefinition{
+		Name:        p.Name,
+		Description: p.Description,
+		Template:    p.Template,
+		Tools:       p.Tools,
+	}
+	for _, arg := range p.Arguments {
+		prompt.Arguments = append(prompt.Arguments, PromptArgumentDefinition(arg))
+	}
+	return prompt
+}
+
+// backendConfig 转换为 BackendConfig，单独设置的用户名和密码优先于 URL 中的
+func (b backendFileConfig) backendConfig() (BackendConfig, error) {
+	if b.Name == "" {
+		return BackendConfig{}, fmt.Errorf("后端缺少 name")
+	}
+
+	var backend BackendConfig
+	if len(b.LocalRoots) > 0 {
+		backend = BackendConfig{Name: b.Name, Type: backendLocal, LocalRoots: b.LocalRoots}
+	} else {
+		if b.URL == "" {
+			return BackendConfig{}, fmt.Errorf("后端 %s 需要设置 url 或 local_roots", b.Name)
+		}
+		var err error
+		backend, err = parseBackendURL(b.Name, b.URL)
+		if err != nil {
+			return BackendConfig{}, err
+		}
+		if b.Username != "" {
+			backend.Username = b.Username
+		}
+		if b.Password != "" {
+			backend.Password = b.Password
+		}
+		if backend.Password == "" {
+			backend.Password = os.Getenv(backendPasswordEnv(b.Name))
+		}
+	}
+	if b.Timeout != nil {
+		backend.Timeout = time.Duration(*b.Timeout)
+	}
+	return backend, nil
+}
+
+// configLoader 按 默认值 < 配置文件（顶层、profile） < 环境变量 < 命令行参数 的优先级生成配置
+// 保存来源而不是结果，以便重新读取配置文件
+type configLoader struct {
+	// path 配置文件路径，为空时不读取配置文件
+	path string
+	// profile 命令行或环境变量指定的 profile，为空时使用配置文件中的 profile
+	profile string
+	// flags 命令行中显式设置的配置参数
+	flags map[string]string
+}
+
+// load 生成配置
+func (l *configLoader) load() (*EverythingConfig, error) {
+	config := DefaultConfig()
+
+	if l.path != "" {
+		file, err := loadConfigFile(l.path)
+		if err != nil {
+			return nil, err
+		}
+		if err := file.profileConfig.apply(config); err != nil {
+			return nil, fmt.Errorf("配置文件 %s: %w", l.path, err)
+		}
+
+		profile := l.profile
+		if profile == "" {
+			profile = file.Profile
+		}
+		if profile != "" {
+			p, ok := file.Profiles[profile]
+			if !ok {
+				return nil, fmt.Errorf("配置文件 %s 中没有 profile %q（可选: %s）", l.path, profile, strings.Join(profileNames(file), ", "))
+			}
+			if err := p.apply(config); err != nil {
+				return nil, fmt.Errorf("配置文件 %s 的 profile %s: %w", l.path, profile, err)
+			}
+		}
+	} else if l.profile != "" {
+		return nil, fmt.Errorf("指定了 profile %q，但没有找到配置文件", l.profile)
+	}
+
+	// 环境变量和命令行参数共用同一组参数定义：EVERYTHING_ 加上大写的参数名，例如 --cache-ttl 对应 EVERYTHING_CACHE_TTL
+	flags := flag.NewFlagSet("config", flag.ContinueOnError)
+	registerConfigFlags(flags, config)
+	var err error
+	flags.VisitAll(func(f *flag.Flag) {
+		name := "EVERYTHING_" + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		if v, ok := os.LookupEnv(name); ok && v != "" && err == nil {
+			if setErr := flags.Set(f.Name, v); setErr != nil {
+				err = fmt.Errorf("无效的环境变量 %s=%q: %w", name, v, setErr)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	// 密码只能通过环境变量或配置文件设置，避免出现在进程列表中
+	if v := os.Getenv("EVERYTHING_PASSWORD"); v != "" {
+		config.Password = v
+	}
+
+	for name, value := range l.flags {
+		if err := flags.Set(name, value); err != nil {
+			return nil, fmt.Errorf("无效的参数 --%s=%q: %w", name, value, err)
+		}
+	}
+	return config, nil
+}
+
+// profileNames 返回配置文件中的 profile 名称，按字母排序
+func profileNames(file *configFile) []string {
+	names := make([]string, 0, len(file.Profiles))
+	for name := range file.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// registerConfigFlags 注册与配置项对应的命令行参数，参数值直接写入 config
+func registerConfigFlags(flags *flag.FlagSet, config *EverythingConfig) {
+	flags.StringVar(&config.BaseURL, "base-url", config.BaseURL, "Everything HTTP 服务器地址，例如 http://localhost")
+	flags.IntVar(&config.Port, "port", config.Port, "Everything HTTP 服务器端口")
+	flags.StringVar(&config.Username, "username", config.Username, "Everything HTTP 服务器用户名（密码请通过 EVERYTHING_PASSWORD 或配置文件设置）")
+	flags.DurationVar(&config.Timeout, "timeout", config.Timeout, "每次请求 Everything 的超时时间")
+	flags.IntVar(&config.MaxInFlight, "max-in-flight", config.MaxInFlight, "stdio 模式下同时处理的最大请求数")
+	// 搜索后端：everything（默认）或 local（没有 Everything 的机器上索引本地目录）
+	flags.StringVar(&config.Backend, "backend", config.Backend, "搜索后端: everything 或 local")
+	flags.Var((*pathListValue)(&config.LocalRoots), "local-roots",
+		fmt.Sprintf("local 后端索引的根目录，多个目录用 %q 分隔", string(os.PathListSeparator)))
+	flags.DurationVar(&config.LocalRescan, "local-rescan", config.LocalRescan, "local 后端重建索引的间隔")
+	// 联合搜索：多个命名后端，例如 ws1=http://10.0.0.5:8080,fs=http://user@fileserver
+	flags.Var(&backendListValue{backends: &config.Backends}, "backends",
+		"联合搜索的后端列表: name=url 或 name=local:目录，逗号分隔（密码请通过 EVERYTHING_BACKEND_<NAME>_PASSWORD 或配置文件设置）")
+	// 搜索结果缓存：默认关闭，设置有效期（例如 30s）后启用
+	flags.DurationVar(&config.CacheTTL, "cache-ttl", config.CacheTTL, "搜索结果缓存的有效期，0 表示不缓存")
+	flags.Var((*megabytesValue)(&config.CacheMaxBytes), "cache-max-mb", "搜索结果缓存的最大内存占用（MB）")
+	// 重试和熔断：Everything 主机休眠或重启时避免每次调用都等满超时
+	flags.IntVar(&config.Retries, "retries", config.Retries, "连接失败或服务器错误时的重试次数")
+	flags.DurationVar(&config.RetryBackoff, "retry-backoff", config.RetryBackoff, "第一次重试前的等待时间，之后按指数增长")
+	flags.IntVar(&config.BreakerThreshold, "breaker-threshold", config.BreakerThreshold, "连续失败多少次后熔断，0 表示不熔断")
+	flags.DurationVar(&config.BreakerProbe, "breaker-probe", config.BreakerProbe, "熔断期间探测后端是否恢复的间隔")
+	flags.StringVar(&config.DisplayTimezone, "timezone", config.DisplayTimezone, "文本结果显示时间使用的时区，例如 Asia/Shanghai、UTC，默认使用本地时区")
+	flags.IntVar(&config.MaxResults, "max-results", config.MaxResults, "工具未指定 max_results 时返回的结果数")
+	flags.IntVar(&config.MaxResultsLimit, "max-results-limit", config.MaxResultsLimit, "工具 max_results 的上限，0 表示不限制")
+	// 工具开关：名称或分组（search、browse、cache、all），逗号分隔；按工具的 max_results 只能在配置文件中设置
+	flags.Var((*commaListValue)(&config.Tools), "tools", "启用的工具名称或分组，逗号分隔，默认全部启用")
+	flags.Var((*commaListValue)(&config.DisabledTools), "disabled-tools", "禁用的工具名称或分组，逗号分隔")
+	// 路径访问策略：所有工具的结果都限制在根目录中，并隐藏匹配规则的路径
+	flags.Var((*commaListValue)(&config.AllowedRoots), "allowed-roots", "允许访问的根目录，逗号分隔，默认不限制")
+	flags.Var((*commaListValue)(&config.DenyGlobs), "deny-globs", "隐藏的路径规则，逗号分隔，例如 .ssh,id_rsa*,C:/Users/*/AppData（替换默认规则）")
+	flags.DurationVar(&config.ResourcePollInterval, "resource-poll-interval", config.ResourcePollInterval, "订阅的搜索结果资源的轮询间隔")
+}
+
+// commaListValue 逗号分隔的列表
+type commaListValue []string
+
+func (v *commaListValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return strings.Join(*v, ",")
+}
+
+func (v *commaListValue) Set(s string) error {
+	*v = nil
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*v = append(*v, item)
+		}
+	}
+	return nil
+}
+
+// pathListValue 用系统路径分隔符分隔的目录列表
+type pathListValue []string
+
+func (v *pathListValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return strings.Join(*v, string(os.PathListSeparator))
+}
+
+func (v *pathListValue) Set(s string) error {
+	*v = filepath.SplitList(s)
+	return nil
+}
+
+// backendListValue --backends 的值，保留原始文本以便重新设置
+type backendListValue struct {
+	backends *[]BackendConfig
+	spec     string
+}
+
+func (v *backendListValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return v.spec
+}
+
+func (v *backendListValue) Set(s string) error {
+	backends, err := parseBackendList(s)
+	if err != nil {
+		return err
+	}
+	*v.backends = backends
+	v.spec = s
+	return nil
+}
+
+// megabytesValue 以 MB 为单位设置的字节数
+type megabytesValue int64
+
+func (v *megabytesValue) String() string {
+	if v == nil {
+		return "0"
+	}
+	return strconv.FormatInt(int64(*v)>>20, 10)
+}
+
+func (v *megabytesValue) Set(s string) error {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	*v = megabytesValue(n << 20)
+	return nil
+}
+
+// printConfig 以 YAML 格式输出生效的配置，密码显示为 ********
+func printConfig(w io.Writer, config *EverythingConfig) error {
+	mask := func(secret string) string {
+		if secret == "" {
+			return ""
+		}
+		return maskedSecret
+	}
+	timeout := duration(config.Timeout)
+	localRescan := duration(config.LocalRescan)
+	cacheTTL := duration(config.CacheTTL)
+	cacheMaxMB := int(config.CacheMaxBytes >> 20)
+	retryBackoff := duration(config.RetryBackoff)
+	breakerProbe := duration(config.BreakerProbe)
+	resourcePollInterval := duration(config.ResourcePollInterval)
+	password := mask(config.Password)
+
+	p := profileConfig{
+		BaseURL:          &config.BaseURL,
+		Port:             &config.Port,
+		Username:         &config.Username,
+		Password:         &password,
+		Timeout:          &timeout,
+		Backend:          &config.Backend,
+		LocalRoots:       config.LocalRoots,
+		LocalRescan:      &localRescan,
+		MaxInFlight:      &config.MaxInFlight,
+		CacheTTL:         &cacheTTL,
+		CacheMaxMB:       &cacheMaxMB,
+		Retries:          &config.Retries,
+		RetryBackoff:     &retryBackoff,
+		BreakerThreshold: &config.BreakerThreshold,
+		BreakerProbe:     &breakerProbe,
+		Timezone:         &config.DisplayTimezone,
+		MaxResults:       &config.MaxResults,
+		MaxResultsLimit:  &config.MaxResultsLimit,
+		Tools:            config.Tools,
+		DisabledTools:    config.DisabledTools,
+		AllowedRoots:     config.AllowedRoots,
+		DenyGlobs:        config.DenyGlobs,
+
+		ResourcePollInterval: &resourcePollInterval,
+	}
+	for name, l := range config.ToolLimits {
+		if p.ToolLimits == nil {
+			p.ToolLimits = make(map[string]toolLimitsConfig)
+		}
+		var limits toolLimitsConfig
+		if l.MaxResults > 0 {
+			limits.MaxResults = &l.MaxResults
+		}
+		if l.MaxResultsLimit > 0 {
+			limits.MaxResultsLimit = &l.MaxResultsLimit
+		}
+		p.ToolLimits[name] = limits
+	}
+	for _, prompt := range config.Prompts {
+		file := promptFileConfig{
+			Name:        prompt.Name,
+			Description: prompt.Description,
+			Template:    prompt.Template,
+			Tools:       prompt.Tools,
+		}
+		for _, arg := range prompt.Arguments {
+			file.Arguments = append(file.Arguments, promptArgumentFileConfig(arg))
+		}
+		p.Prompts = append(p.Prompts, file)
+	}
+	for _, b := range config.Backends {
+		backend := backendFileConfig{
+			Name:       b.Name,
+			Username:   b.Username,
+			Password:   mask(b.Password),
+			LocalRoots: b.LocalRoots,
+		}
+		if b.Type != backendLocal {
+			backend.URL = fmt.Sprintf("%s:%d", b.BaseURL, b.Port)
+		}
+		if b.Timeout > 0 {
+			t := duration(b.Timeout)
+			backend.Timeout = &t
+		}
+		p.Backends = append(p.Backends, backend)
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(p); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
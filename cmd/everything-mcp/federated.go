@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BackendConfig 联合搜索中的一个命名后端
+type BackendConfig struct {
+	// Name 后端名称，用于标记结果来源
+	Name string
+	// Type 后端类型: everything 或 local
+	Type string
+
+	// everything 后端的连接参数
+	BaseURL  string
+	Port     int
+	Username string
+	Password string
+	// Timeout 单个后端的超时时间，<= 0 时使用全局 Timeout
+	Timeout time.Duration
+
+	// local 后端的根目录和重建间隔
+	LocalRoots  []string
+	LocalRescan time.Duration
+}
+
+// parseBackendList 解析 --backends 的值：逗号分隔的 name=target 列表
+// target 为 Everything HTTP 地址（可以在 URL 中带用户名，例如 http://user@host:8080），
+// 或者 local: 加上本地根目录列表（例如 local:/srv/data:/home）
+// 密码不能写在 URL 中，避免出现在进程列表中；通过 backendPasswordEnv 对应的环境变量或配置文件设置
+func parseBackendList(spec string) ([]BackendConfig, error) {
+	var backends []BackendConfig
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, target, ok := strings.Cut(item, "=")
+		name, target = strings.TrimSpace(name), strings.TrimSpace(target)
+		if !ok || name == "" || target == "" {
+			return nil, fmt.Errorf("无效的后端配置 %q，格式为 name=url 或 name=local:目录", item)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("后端名称重复: %s", name)
+		}
+		seen[name] = true
+
+		if roots, ok := strings.CutPrefix(target, "local:"); ok {
+			backends = append(backends, BackendConfig{
+				Name:       name,
+				Type:       backendLocal,
+				LocalRoots: filepath.SplitList(roots),
+			})
+			continue
+		}
+
+		backend, err := parseBackendURL(name, target)
+		if err != nil {
+			return nil, err
+		}
+		if backend.Password != "" {
+			return nil, fmt.Errorf("后端 %s 的地址中不能包含密码，请通过环境变量 %s 或配置文件设置", name, backendPasswordEnv(name))
+		}
+		backend.Password = os.Getenv(backendPasswordEnv(name))
+		backends = append(backends, backend)
+	}
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("没有配置任何后端")
+	}
+	return backends, nil
+}
+
+// parseBackendURL 解析 Everything 后端地址，可以在 URL 中带用户名和密码
+func parseBackendURL(name, target string) (BackendConfig, error) {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return BackendConfig{}, fmt.Errorf("无效的后端地址 %q（后端 %s）", target, name)
+	}
+	backend := BackendConfig{
+		Name:    name,
+		Type:    backendEverything,
+		BaseURL: u.Scheme + "://" + u.Hostname(),
+	}
+	if p := u.Port(); p != "" {
+		backend.Port, _ = strconv.Atoi(p)
+	} else if u.Scheme == "https" {
+		backend.Port = 443
+	} else {
+		backend.Port = 80
+	}
+	if u.User != nil {
+		backend.Username = u.User.Username()
+		backend.Password, _ = u.User.Password()
+	}
+	return backend, nil
+}
+
+// backendPasswordEnv 后端密码对应的环境变量，例如后端 fs 为 EVERYTHING_BACKEND_FS_PASSWORD
+func backendPasswordEnv(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(name) {
+		if ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return "EVERYTHING_BACKEND_" + b.String() + "_PASSWORD"
+}
+
+// namedSearcher 带名称的搜索后端
+type namedSearcher struct {
+	name     string
+	searcher EverythingSearcher
+	timeout  time.Duration
+}
+
+// FederatedSearcher 将每次搜索并发发送到多个后端，合并、排序后返回
+// 单个后端失败或超时只会产生警告，所有后端都失败时才返回错误
+type FederatedSearcher struct {
+	backends []namedSearcher
+}
+
+// NewFederatedSearcher 根据后端列表创建联合搜索，defaults 提供未在后端中单独设置的参数（例如超时）
+func NewFederatedSearcher(backends []BackendConfig, defaults *EverythingConfig) (*FederatedSearcher, error) {
+	f := &FederatedSearcher{}
+	for _, b := range backends {
+		timeout := b.Timeout
+		if timeout <= 0 {
+			timeout = defaults.Timeout
+		}
+		config := &EverythingConfig{
+			BaseURL:     b.BaseURL,
+			Port:        b.Port,
+			Username:    b.Username,
+			Password:    b.Password,
+			Timeout:     timeout,
+			Backend:     b.Type,
+			LocalRoots:  b.LocalRoots,
+			LocalRescan: b.LocalRescan,
+			// 重试和熔断参数对所有后端相同，每个后端有独立的熔断器
+			Retries:          defaults.Retries,
+			RetryBackoff:     defaults.RetryBackoff,
+			BreakerThreshold: defaults.BreakerThreshold,
+			BreakerProbe:     defaults.BreakerProbe,
+		}
+		searcher, err := newSearcher(config)
+		if err != nil {
+			return nil, fmt.Errorf("后端 %s: %w", b.Name, err)
+		}
+		f.backends = append(f.backends, namedSearcher{name: b.Name, searcher: searcher, timeout: timeout})
+	}
+	return f, nil
+}
+
+// Search 并发查询所有后端并合并结果
+// 合并后的排序顺序只有在取得每个后端的前 offset+maxResults 个结果后才能确定，
+// 因此每个后端都从 0 开始查询，合并后再按 offset 截取
+// 请求携带 progressToken 时，每个后端完成（或失败）后发送一次进度通知
+func (f *FederatedSearcher) Search(ctx context.Context, opts SearchOptions) (*SearchResponse, error) {
+	backendOpts := opts
+	backendOpts.Offset = 0
+	if opts.MaxResults > 0 {
+		backendOpts.MaxResults = opts.Offset + opts.MaxResults
+	}
+
+	type backendResult struct {
+		response *SearchResponse
+		err      error
+	}
+	results := make([]backendResult, len(f.backends))
+
+	var mu sync.Mutex
+	finished := 0
+	var wg sync.WaitGroup
+	for i, b := range f.backends {
+		wg.Add(1)
+		go func(i int, b namedSearcher) {
+			defer wg.Done()
+			bctx := ctx
+			if b.timeout > 0 {
+				var cancel context.CancelFunc
+				bctx, cancel = context.WithTimeout(ctx, b.timeout)
+				defer cancel()
+			}
+			response, err := b.searcher.Search(bctx, backendOpts)
+			results[i] = backendResult{response: response, err: err}
+
+			mu.Lock()
+			defer mu.Unlock()
+			finished++
+			message := fmt.Sprintf("后端 %s 已返回", b.name)
+			if err != nil {
+				message = fmt.Sprintf("后端 %s 失败", b.name)
+			}
+			reportProgress(ctx, finished, len(f.backends), message)
+		}(i, b)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	merged := &SearchResponse{}
+	var failures []string
+	for i, r := range results {
+		name := f.backends[i].name
+		if r.err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", name, r.err))
+			continue
+		}
+		merged.TotalResults += r.response.TotalResults
+		merged.Warnings = append(merged.Warnings, r.response.Warnings...)
+		for _, result := range r.response.Results {
+			if result.Source == "" {
+				result.Source = name
+			}
+			merged.Results = append(merged.Results, result)
+		}
+	}
+	if len(failures) == len(f.backends) {
+		return nil, fmt.Errorf("所有后端均失败: %s", strings.Join(failures, "; "))
+	}
+	for _, failure := range failures {
+		merged.Warnings = append(merged.Warnings, "部分结果: 后端 "+failure)
+	}
+
+	sortSearchResults(merged.Results, opts.Sort)
+
+	start := opts.Offset
+	if start > len(merged.Results) {
+		start = len(merged.Results)
+	}
+	end := len(merged.Results)
+	if opts.MaxResults > 0 && start+opts.MaxResults < end {
+		end = start + opts.MaxResults
+	}
+	merged.Results = merged.Results[start:end]
+	return merged, nil
+}
+
+// close 释放所有后端的后台资源
+func (f *FederatedSearcher) close() {
+	for _, b := range f.backends {
+		closeSearcher(b.searcher)
+	}
+}
+
+// Backend 返回指定名称的单个后端（名称不区分大小写），结果的 Source 设置为后端名称
+func (f *FederatedSearcher) Backend(name string) (EverythingSearcher, bool) {
+	for _, b := range f.backends {
+		if strings.EqualFold(b.name, name) {
+			return b, true
+		}
+	}
+	return nil, false
+}
+
+// Search 只查询这一个后端，使用后端的超时设置
+func (b namedSearcher) Search(ctx context.Context, opts SearchOptions) (*SearchResponse, error) {
+	if b.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.timeout)
+		defer cancel()
+	}
+	response, err := b.searcher.Search(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range response.Results {
+		if response.Results[i].Source == "" {
+			response.Results[i].Source = b.name
+		}
+	}
+	return response, nil
+}
+
+// sortSearchResults 按 Everything 的排序语义合并排序，未指定排序字段时按名称升序
+func sortSearchResults(results []SearchResult, order SearchSort) {
+	field := order.Field
+	ascending := order.Ascending
+	if field == "" {
+		field, ascending = "name", true
+	}
+
+	name := func(r SearchResult) string {
+		if i := strings.LastIndexAny(r.Path, `\/`); i >= 0 {
+			return strings.ToLower(r.Path[i+1:])
+		}
+		return strings.ToLower(r.Path)
+	}
+	compare := func(a, b SearchResult) int {
+		switch field {
+		case "path":
+			if c := strings.Compare(strings.ToLower(a.Path), strings.ToLower(b.Path)); c != 0 {
+				return c
+			}
+		case "size":
+			if a.Size != b.Size {
+				if a.Size < b.Size {
+					return -1
+				}
+				return 1
+			}
+		case "date_modified":
+			if c := a.DateModified.Compare(b.DateModified); c != 0 {
+				return c
+			}
+		}
+		return strings.Compare(name(a), name(b))
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		c := compare(results[i], results[j])
+		if ascending {
+			return c < 0
+		}
+		return c > 0
+	})
+}
+
+// displayPath 返回结果在文本中的显示形式，联合搜索时带上来源，例如 "[ws1] C:\foo"
+func displayPath(result SearchResult) string {
+	return withSource(result, result.Path)
+}
+
+// withSource 联合搜索时在文本前加上结果来源
+func withSource(result SearchResult, text string) string {
+	if result.Source == "" {
+		return text
+	}
+	return fmt.Sprintf("[%s] %s", result.Source, text)
+}
+
+// formatWarnings 生成警告文本，附加在结果之后
+func formatWarnings(warnings []string) string {
+	text := ""
+	for _, w := range warnings {
+		text += fmt.Sprintf("警告: %s\n", w)
+	}
+	return text
+}
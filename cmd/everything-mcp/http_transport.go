@@ -0,0 +1,497 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// mcpSessionHeader Streamable HTTP 规范中用于传递会话 ID 的请求/响应头
+const mcpSessionHeader = "Mcp-Session-Id"
+
+// mcpHTTPPath Streamable HTTP 的 MCP 端点路径
+const mcpHTTPPath = "/mcp"
+
+// defaultSessionIdleTimeout 会话默认的空闲超时
+const defaultSessionIdleTimeout = 30 * time.Minute
+
+// maxHTTPMessageSize 单个 POST 请求体的最大字节数
+const maxHTTPMessageSize = 4 << 20
+
+// httpOptions Streamable HTTP 传输的访问控制
+type httpOptions struct {
+	// AllowedOrigins 除本机地址外允许的浏览器来源（Origin 请求头），例如 https://chat.example.com
+	AllowedOrigins []string
+	// Token 不为空时，请求必须携带 Authorization: Bearer <Token>
+	Token string
+	// SessionIdleTimeout 会话没有请求也没有打开的 SSE 流超过这个时间后被结束，
+	// 用于清理没有发送 DELETE 就断开的客户端
+	SessionIdleTimeout time.Duration
+}
+
+// httpSession 一个 Streamable HTTP 客户端会话
+type httpSession struct {
+	id       string
+	requests *inflightRequests // 该会话正在处理的请求
+	// unsubscribe 停止接收广播的通知，会话结束时调用
+	unsubscribe func()
+	// subscriber 会话的资源订阅者，更新通知推送到 SSE 流
+	subscriber *subscriber
+
+	mu      sync.Mutex
+	streams map[chan []byte]struct{} // 通过 GET 打开的 SSE 流
+	// busy 正在处理的 POST 请求数，lastActive 最后一次请求结束或 SSE 流关闭的时间
+	busy       int
+	lastActive time.Time
+}
+
+// acquire 标记会话正在使用（处理请求或 SSE 流打开期间），返回结束使用的函数
+func (hs *httpSession) acquire() func() {
+	hs.mu.Lock()
+	hs.busy++
+	hs.mu.Unlock()
+	return func() {
+		hs.mu.Lock()
+		hs.busy--
+		hs.lastActive = time.Now()
+		hs.mu.Unlock()
+	}
+}
+
+// idleSince 会话空闲的起始时间，正在使用时返回 false
+func (hs *httpSession) idleSince() (time.Time, bool) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	if hs.busy > 0 || len(hs.streams) > 0 {
+		return time.Time{}, false
+	}
+	return hs.lastActive, true
+}
+
+// send 将服务器主动发起的消息推送到该会话所有打开的 SSE 流
+// 没有打开的流时消息会被丢弃
+func (hs *httpSession) send(msg []byte) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	for stream := range hs.streams {
+		select {
+		case stream <- msg:
+		default:
+			// 客户端读取过慢，丢弃该消息，避免阻塞服务器
+		}
+	}
+}
+
+// httpTransport 实现 MCP Streamable HTTP 传输：
+// POST 发送 JSON-RPC 消息，GET 打开 SSE 流接收服务器消息，DELETE 结束会话
+type httpTransport struct {
+	mcpServer requestHandler
+	options   httpOptions
+	// clients 广播的通知（例如 notifications/tools/list_changed）推送到每个会话
+	clients *broadcaster
+
+	mu       sync.Mutex
+	sessions map[string]*httpSession
+}
+
+// newHTTPTransport 创建新的 Streamable HTTP 传输
+func newHTTPTransport(mcpServer requestHandler, clients *broadcaster, options httpOptions) *httpTransport {
+	return &httpTransport{
+		mcpServer: mcpServer,
+		options:   options,
+		clients:   clients,
+		sessions:  make(map[string]*httpSession),
+	}
+}
+
+// ServeHTTP 实现 http.Handler
+func (t *httpTransport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// 校验 Origin，防止 DNS 重绑定攻击让浏览器中的网页访问本服务器
+	if origin := r.Header.Get("Origin"); origin != "" && !t.originAllowed(origin) {
+		http.Error(w, "不允许的 Origin", http.StatusForbidden)
+		return
+	}
+	if !t.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="everything-mcp"`)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		t.handlePost(w, r)
+	case http.MethodGet:
+		t.handleGet(w, r)
+	case http.MethodDelete:
+		t.handleDelete(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// originAllowed 判断浏览器来源是否允许访问：本机地址（localhost、127.0.0.1、::1）和 AllowedOrigins 中的来源
+func (t *httpTransport) originAllowed(origin string) bool {
+	for _, allowed := range t.options.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimRight(allowed, "/"), origin) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	host := u.Hostname()
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// authorized 校验 Bearer token，未配置 token 时不校验
+func (t *httpTransport) authorized(r *http.Request) bool {
+	if t.options.Token == "" {
+		return true
+	}
+	auth := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if len(auth) < len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(auth[len(prefix):]), []byte(t.options.Token)) == 1
+}
+
+// handlePost 处理客户端发送的 JSON-RPC 消息
+func (t *httpTransport) handlePost(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxHTTPMessageSize+1))
+	if err != nil {
+		http.Error(w, "读取请求失败", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxHTTPMessageSize {
+		http.Error(w, "请求体过大", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// 无法解析的消息返回 JSON-RPC 解析错误（-32700）
+	if !json.Valid(body) {
+		response, _ := json.Marshal(errorResponse(nil, newRPCError(codeParseError, "无效的 JSON")))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response)
+		return
+	}
+
+	// initialize 会创建会话，必须单独发送；批量请求中包含它时返回 -32600
+	if batchContainsInitialize(body) {
+		response, _ := json.Marshal(errorResponse(nil, newRPCError(codeInvalidRequest, "无效的请求: initialize 不能放在批量请求中")))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(response)
+		return
+	}
+
+	// 批量请求（数组）按普通请求处理
+	var envelope struct {
+		Method string `json:"method"`
+		Params struct {
+			Meta struct {
+				ProgressToken interface{} `json:"progressToken"`
+			} `json:"_meta"`
+		} `json:"params"`
+	}
+	json.Unmarshal(body, &envelope)
+
+	// 除 initialize 外，所有请求都必须携带有效的会话 ID
+	isInitialize := envelope.Method == "initialize"
+	session := &clientSession{requests: newInflightRequests()}
+	if !isInitialize {
+		sess, status := t.lookupSession(r)
+		if status != http.StatusOK {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		session.requests = sess.requests
+		session.notify = sess.send
+		session.subscriber = sess.subscriber
+		defer sess.acquire()()
+	}
+
+	// 请求携带 progressToken 且客户端接受 SSE 时，以 SSE 流返回：
+	// 先推送进度通知，最后推送响应
+	flusher, canFlush := w.(http.Flusher)
+	if envelope.Params.Meta.ProgressToken != nil && canFlush &&
+		strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		var mu sync.Mutex
+		writeEvent := func(msg []byte) {
+			mu.Lock()
+			defer mu.Unlock()
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", msg)
+			flusher.Flush()
+		}
+		session.notify = writeEvent
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		response, _ := handleMessageWithNotifications(r.Context(), t.mcpServer, session, string(body))
+		if response != nil {
+			writeEvent(response)
+		}
+		return
+	}
+
+	response, err := handleMessageWithNotifications(r.Context(), t.mcpServer, session, string(body))
+	if response == nil {
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// 通知、响应以及已被取消的请求不需要返回内容
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	if isInitialize && err == nil {
+		sess, err := t.newSession()
+		if err != nil {
+			http.Error(w, "创建会话失败", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(mcpSessionHeader, sess.id)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+// batchContainsInitialize 判断消息是否为包含 initialize 请求的批量请求
+func batchContainsInitialize(body []byte) bool {
+	var batch []json.RawMessage
+	if err := json.Unmarshal(body, &batch); err != nil {
+		return false
+	}
+	for _, msg := range batch {
+		var m struct {
+			Method string `json:"method"`
+		}
+		if json.Unmarshal(msg, &m) == nil && m.Method == "initialize" {
+			return true
+		}
+	}
+	return false
+}
+
+// handleGet 打开 SSE 流，用于接收服务器主动发起的消息
+func (t *httpTransport) handleGet(w http.ResponseWriter, r *http.Request) {
+	if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		http.Error(w, "需要 Accept: text/event-stream", http.StatusNotAcceptable)
+		return
+	}
+
+	sess, status := t.lookupSession(r)
+	if status != http.StatusOK {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "不支持流式响应", http.StatusInternalServerError)
+		return
+	}
+
+	stream := make(chan []byte, 16)
+	sess.mu.Lock()
+	sess.streams[stream] = struct{}{}
+	sess.mu.Unlock()
+	defer func() {
+		sess.mu.Lock()
+		delete(sess.streams, stream)
+		sess.lastActive = time.Now()
+		sess.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// 定期发送注释行，防止代理关闭空闲连接
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg := <-stream:
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", msg)
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// handleDelete 由客户端显式结束会话
+func (t *httpTransport) handleDelete(w http.ResponseWriter, r *http.Request) {
+	sess, status := t.lookupSession(r)
+	if status != http.StatusOK {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	t.closeSession(sess)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// closeSession 结束会话：停止接收广播的通知，取消资源订阅
+func (t *httpTransport) closeSession(sess *httpSession) {
+	t.mu.Lock()
+	if t.sessions[sess.id] != sess {
+		t.mu.Unlock()
+		return
+	}
+	delete(t.sessions, sess.id)
+	t.mu.Unlock()
+	sess.unsubscribe()
+	sess.subscriber.close()
+}
+
+// reapIdleSessions 定期结束空闲超过 SessionIdleTimeout 的会话，直到 ctx 结束
+func (t *httpTransport) reapIdleSessions(ctx context.Context) {
+	timeout := t.options.SessionIdleTimeout
+	if timeout <= 0 {
+		timeout = defaultSessionIdleTimeout
+	}
+	interval := timeout / 4
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		t.mu.Lock()
+		sessions := make([]*httpSession, 0, len(t.sessions))
+		for _, sess := range t.sessions {
+			sessions = append(sessions, sess)
+		}
+		t.mu.Unlock()
+
+		for _, sess := range sessions {
+			if since, idle := sess.idleSince(); idle && time.Since(since) > timeout {
+				if os.Getenv("EVERYTHING_DEBUG") == "true" {
+					log.Printf("会话 %s 空闲超过 %s，已结束\n", sess.id, timeout)
+				}
+				t.closeSession(sess)
+			}
+		}
+	}
+}
+
+// newSession 创建并登记一个新会话
+func (t *httpTransport) newSession() (*httpSession, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+
+	sess := &httpSession{
+		id:         hex.EncodeToString(buf),
+		requests:   newInflightRequests(),
+		streams:    make(map[chan []byte]struct{}),
+		lastActive: time.Now(),
+	}
+	sess.unsubscribe = t.clients.subscribe(sess.send)
+	sess.subscriber = newSubscriber(sess.send)
+
+	t.mu.Lock()
+	t.sessions[sess.id] = sess
+	t.mu.Unlock()
+
+	return sess, nil
+}
+
+// lookupSession 根据请求头查找会话
+// 缺少会话 ID 返回 400，会话不存在（或已结束）返回 404
+func (t *httpTransport) lookupSession(r *http.Request) (*httpSession, int) {
+	id := r.Header.Get(mcpSessionHeader)
+	if id == "" {
+		return nil, http.StatusBadRequest
+	}
+
+	t.mu.Lock()
+	sess, ok := t.sessions[id]
+	t.mu.Unlock()
+	if !ok {
+		return nil, http.StatusNotFound
+	}
+	return sess, http.StatusOK
+}
+
+// serveStreamableHTTP 通过 Streamable HTTP 传输启动 MCP 服务器
+// 多个客户端可以共享同一个服务器进程
+func serveStreamableHTTP(addr string, transport *httpTransport) error {
+	mux := http.NewServeMux()
+	mux.Handle(mcpHTTPPath, transport)
+
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go transport.reapIdleSessions(ctx)
+
+	// 处理信号，优雅关闭
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(sigChan)
+
+	go func() {
+		<-sigChan
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(ctx)
+	}()
+
+	if os.Getenv("EVERYTHING_DEBUG") == "true" {
+		log.Printf("MCP Streamable HTTP 监听地址: http://%s%s\n", addr, mcpHTTPPath)
+	}
+
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
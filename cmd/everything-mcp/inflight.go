@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// inflightRequest 一个正在处理的请求
+type inflightRequest struct {
+	cancel    context.CancelFunc
+	cancelled bool
+}
+
+// inflightRequests 按 JSON-RPC id 登记正在处理的请求，
+// 收到 notifications/cancelled 时取消对应请求的 context
+// 每个客户端会话（stdio 进程或 HTTP 会话）各自持有一个，id 只在会话内唯一
+type inflightRequests struct {
+	mu       sync.Mutex
+	requests map[string]*inflightRequest
+}
+
+// newInflightRequests 创建新的请求登记表
+func newInflightRequests() *inflightRequests {
+	return &inflightRequests{
+		requests: make(map[string]*inflightRequest),
+	}
+}
+
+// requestKey 将 JSON-RPC id 转换为登记表的键
+// 使用 JSON 编码区分字符串 id 和数字 id（"1" 与 1 是不同的请求）
+func requestKey(id interface{}) string {
+	key, _ := json.Marshal(id)
+	return string(key)
+}
+
+// begin 登记一个请求，返回可被取消的 context 以及结束函数
+// 结束函数返回该请求是否已被客户端取消，已取消的请求不应再发送响应
+func (r *inflightRequests) begin(ctx context.Context, id interface{}) (context.Context, func() bool) {
+	ctx, cancel := context.WithCancel(ctx)
+	req := &inflightRequest{cancel: cancel}
+	key := requestKey(id)
+
+	r.mu.Lock()
+	r.requests[key] = req
+	r.mu.Unlock()
+
+	return ctx, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.requests[key] == req {
+			delete(r.requests, key)
+		}
+		cancel()
+		return req.cancelled
+	}
+}
+
+// cancel 取消指定 id 的请求，请求不存在（已完成或未知）时返回 false
+func (r *inflightRequests) cancel(id interface{}) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	req, ok := r.requests[requestKey(id)]
+	if !ok {
+		return false
+	}
+	req.cancelled = true
+	req.cancel()
+	return true
+}
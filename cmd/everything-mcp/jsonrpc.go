@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// JSON-RPC 2.0 错误码
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+
+	// codeResourceNotFound MCP 规定的资源不存在错误
+	codeResourceNotFound = -32002
+)
+
+// rpcError JSON-RPC 错误
+// requestHandler 返回 *rpcError 时使用其中的错误码，返回其他错误时按 -32603 处理
+type rpcError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+// newRPCError 创建 JSON-RPC 错误
+func newRPCError(code int, format string, args ...interface{}) *rpcError {
+	return &rpcError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// invalidParams 参数无法解析或缺少必需的参数
+func invalidParams(format string, args ...interface{}) *rpcError {
+	return newRPCError(codeInvalidParams, format, args...)
+}
+
+// mcpGoError 将 mcp-go 返回的错误转换为对应的 JSON-RPC 错误
+// mcp-go v0.1.0 只返回普通错误，按错误信息区分参数错误
+func mcpGoError(err error) error {
+	msg := err.Error()
+	for _, prefix := range []string{"failed to parse parameters", "missing required field", "ping method does not accept parameters"} {
+		if strings.HasPrefix(msg, prefix) {
+			return invalidParams("无效的参数: %s", msg)
+		}
+	}
+	return err
+}
+
+// rpcResponse JSON-RPC 响应；ID 原样返回请求中的 id（字符串或数字），无法确定时为 null
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// nullID 无法确定请求 id 时响应中使用的 id
+var nullID = json.RawMessage("null")
+
+// errorResponse 创建错误响应，err 不是 *rpcError 时按 -32603 处理
+func errorResponse(id json.RawMessage, err error) *rpcResponse {
+	rpcErr, ok := err.(*rpcError)
+	if !ok {
+		rpcErr = &rpcError{Code: codeInternalError, Message: err.Error()}
+	}
+	if id == nil {
+		id = nullID
+	}
+	return &rpcResponse{JSONRPC: "2.0", ID: id, Error: rpcErr}
+}
+
+// handleMessageWithNotifications 处理一条客户端消息：单个 JSON-RPC 消息，或批量消息组成的数组
+// 返回需要发送给客户端的响应；通知、客户端发来的响应和已被取消的请求不需要响应，
+// 全部不需要响应时返回 nil。返回的 error 只用于记录日志，对应的错误响应已包含在返回值中
+func handleMessageWithNotifications(ctx context.Context, mcpServer requestHandler, session *clientSession, line string) ([]byte, error) {
+	data := bytes.TrimSpace([]byte(line))
+	if !json.Valid(data) {
+		err := newRPCError(codeParseError, "无效的 JSON")
+		response, _ := json.Marshal(errorResponse(nil, err))
+		return response, err
+	}
+
+	if data[0] != '[' {
+		response, err := handleSingleMessage(ctx, mcpServer, session, data)
+		if response == nil {
+			return nil, err
+		}
+		responseBytes, _ := json.Marshal(response)
+		return responseBytes, err
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(data, &batch); err != nil || len(batch) == 0 {
+		err := newRPCError(codeInvalidRequest, "无效的请求: 批量请求不能为空")
+		response, _ := json.Marshal(errorResponse(nil, err))
+		return response, err
+	}
+
+	// 批量中的请求并发处理，响应按请求的顺序返回
+	responses := make([]*rpcResponse, len(batch))
+	errs := make([]error, len(batch))
+	var wg sync.WaitGroup
+	for i, msg := range batch {
+		wg.Add(1)
+		go func(i int, msg json.RawMessage) {
+			defer wg.Done()
+			responses[i], errs[i] = handleSingleMessage(ctx, mcpServer, session, msg)
+		}(i, msg)
+	}
+	wg.Wait()
+
+	var results []*rpcResponse
+	var firstErr error
+	for i, response := range responses {
+		if response != nil {
+			results = append(results, response)
+		}
+		if firstErr == nil {
+			firstErr = errs[i]
+		}
+	}
+	if len(results) == 0 {
+		return nil, firstErr
+	}
+	responseBytes, _ := json.Marshal(results)
+	return responseBytes, firstErr
+}
+
+// handleSingleMessage 处理单个 JSON-RPC 消息，不需要响应时返回 nil
+func handleSingleMessage(ctx context.Context, mcpServer requestHandler, session *clientSession, data json.RawMessage) (*rpcResponse, error) {
+	var msg map[string]json.RawMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		rpcErr := newRPCError(codeInvalidRequest, "无效的请求: 消息必须是 JSON 对象")
+		return errorResponse(nil, rpcErr), rpcErr
+	}
+
+	// id 只能是字符串、数字或 null；原样保留，以便响应中的 id 与请求完全一致
+	id, hasID := msg["id"]
+	if hasID && !isValidID(id) {
+		rpcErr := newRPCError(codeInvalidRequest, "无效的请求: id 必须是字符串或数字")
+		return errorResponse(nil, rpcErr), rpcErr
+	}
+	// 只有没有 id 的消息才是通知；"id": null 的请求也要响应，响应中的 id 为 null
+	isNotification := !hasID
+
+	// 客户端对服务器请求的响应（有 result 或 error，没有 method），本服务器不发送请求，直接忽略
+	_, hasMethod := msg["method"]
+	_, hasResult := msg["result"]
+	_, hasError := msg["error"]
+	if !hasMethod && (hasResult || hasError) {
+		return nil, nil
+	}
+
+	var version, method string
+	if err := json.Unmarshal(msg["jsonrpc"], &version); err != nil || version != "2.0" {
+		return invalidRequest(id, isNotification, `jsonrpc 必须是 "2.0"`)
+	}
+	if err := json.Unmarshal(msg["method"], &method); err != nil || method == "" {
+		return invalidRequest(id, isNotification, "method 必须是非空字符串")
+	}
+	params := msg["params"]
+	if len(params) == 0 || string(params) == "null" {
+		params = json.RawMessage("{}")
+	} else if params[0] != '{' && params[0] != '[' {
+		return invalidRequest(id, isNotification, "params 必须是对象或数组")
+	}
+
+	// 通知不需要响应，即使处理失败也不发送错误
+	if isNotification {
+		// 客户端取消正在处理的请求
+		if method == "notifications/cancelled" {
+			var p struct {
+				RequestID json.RawMessage `json:"requestId"`
+			}
+			if err := json.Unmarshal(params, &p); err == nil && isValidID(p.RequestID) {
+				session.requests.cancel(p.RequestID)
+			}
+		}
+		// notifications/initialized 和其他通知直接忽略
+		return nil, nil
+	}
+
+	// 登记请求，以便客户端通过 notifications/cancelled 中止它
+	ctx, finish := session.requests.begin(ctx, id)
+	defer finish()
+	ctx = withProgress(ctx, progressTokenFromParams(params), session.notify)
+	ctx = withSubscriber(ctx, session.subscriber)
+
+	result, err := mcpServer.Request(ctx, method, params)
+	if finish() {
+		// 请求已被客户端取消，不再发送响应
+		return nil, nil
+	}
+	if err != nil {
+		return errorResponse(id, err), err
+	}
+	return &rpcResponse{JSONRPC: "2.0", ID: id, Result: result}, nil
+}
+
+// invalidRequest 返回 -32600 错误；通知无效时不发送响应
+func invalidRequest(id json.RawMessage, isNotification bool, reason string) (*rpcResponse, error) {
+	err := newRPCError(codeInvalidRequest, "无效的请求: %s", reason)
+	if isNotification {
+		return nil, err
+	}
+	return errorResponse(id, err), err
+}
+
+// isValidID 判断 JSON 值是否可以作为请求 id：字符串、数字或 null
+func isValidID(id json.RawMessage) bool {
+	var v interface{}
+	if len(id) == 0 || json.Unmarshal(id, &v) != nil {
+		return false
+	}
+	switch v.(type) {
+	case nil, string, float64:
+		return true
+	}
+	return false
+}
+
+// isNotificationMessage 判断一行消息是否为通知（没有 id；id 为 null 的是请求）
+// 无法解析的消息和批量消息按请求处理，由 handleMessageWithNotifications 报告错误
+func isNotificationMessage(line string) bool {
+	var msg struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+	}
+	if err := json.Unmarshal([]byte(line), &msg); err != nil {
+		return false
+	}
+	return msg.Method != "" && len(msg.ID) == 0
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type pingHandler struct{}
+
+func (pingHandler) Request(ctx context.Context, method string, params json.RawMessage) (interface{}, error) {
+	return map[string]interface{}{}, nil
+}
+
+func TestNullIDIsRequest(t *testing.T) {
+	session := &clientSession{requests: newInflightRequests()}
+	line := `{"jsonrpc":"2.0","id":null,"method":"ping"}`
+	if isNotificationMessage(line) {
+		t.Errorf("id 为 null 的消息不是通知")
+	}
+	response, err := handleMessageWithNotifications(context.Background(), pingHandler{}, session, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"jsonrpc":"2.0","id":null,"result":{}}`; string(response) != want {
+		t.Errorf("响应 = %s，期望 %s", response, want)
+	}
+
+	// 没有 id 的才是通知，不需要响应
+	line = `{"jsonrpc":"2.0","method":"ping"}`
+	if !isNotificationMessage(line) {
+		t.Errorf("没有 id 的消息应为通知")
+	}
+	if response, _ := handleMessageWithNotifications(context.Background(), pingHandler{}, session, line); response != nil {
+		t.Errorf("通知不应有响应: %s", response)
+	}
+}
+
+func TestHTTPRejectsBatchedInitialize(t *testing.T) {
+	transport := &httpTransport{mcpServer: pingHandler{}}
+	body := `[{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}},{"jsonrpc":"2.0","id":2,"method":"ping"}]`
+	req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	transport.handlePost(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("状态码 = %d，期望 %d", rec.Code, http.StatusBadRequest)
+	}
+	var response rpcResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("响应不是 JSON-RPC 错误: %s", rec.Body)
+	}
+	if response.Error == nil || response.Error.Code != codeInvalidRequest {
+		t.Errorf("响应 = %s，期望 -32600 错误", rec.Body)
+	}
+	if rec.Header().Get(mcpSessionHeader) != "" {
+		t.Errorf("批量请求不应创建会话")
+	}
+}
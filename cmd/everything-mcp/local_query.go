@@ -0,0 +1,454 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// localQuery 编译后的本地查询：各组条件之间是 AND，组内条件之间是 OR（与 Everything 的优先级一致）
+type localQuery struct {
+	groups [][]localTerm
+}
+
+// localTerm 一个条件，negate 表示以 ! 开头
+type localTerm struct {
+	negate bool
+	match  func(*localEntry) bool
+}
+
+// match 判断条目是否满足查询
+func (q *localQuery) match(e *localEntry) bool {
+	for _, group := range q.groups {
+		ok := false
+		for _, term := range group {
+			if term.match(e) != term.negate {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// rawTerm 分词后的一个条件
+type rawTerm struct {
+	negate   bool
+	or       bool   // 与前一个条件之间是 | 连接
+	function string // 搜索函数名（小写），例如 ext、size；普通文本为空
+	value    string // 去掉引号并展开 quot: 后的值
+}
+
+// tokenizeLocalQuery 按 Everything 的规则分词：空格分隔条件，引号内为字面量，
+// | 连接 OR 条件，! 表示取反，quot: 表示字面的双引号
+// 返回按 AND 分组的条件，组内为 OR 关系
+func tokenizeLocalQuery(query string) ([][]rawTerm, error) {
+	var terms []rawTerm
+	var current strings.Builder
+	term := rawTerm{}
+	inQuotes, started, quoted, orPending := false, false, false, false
+
+	flush := func() {
+		if started {
+			term.value = current.String()
+			if !quoted {
+				// 不带引号的 function:value
+				if i := strings.Index(term.value, ":"); i > 0 && isLocalFunction(strings.ToLower(term.value[:i])) {
+					term.function = strings.ToLower(term.value[:i])
+					term.value = term.value[i+1:]
+				}
+			}
+			term.value = strings.ReplaceAll(term.value, "quot:", `"`)
+			term.or = orPending && len(terms) > 0
+			terms = append(terms, term)
+			orPending = false
+		}
+		current.Reset()
+		term = rawTerm{}
+		started, quoted = false, false
+	}
+
+	for _, r := range query {
+		switch {
+		case r == '"':
+			started = true
+			if !inQuotes && !quoted && term.function == "" {
+				// function:"value" 形式：引号前的部分是函数名
+				prefix := current.String()
+				if name := strings.ToLower(strings.TrimSuffix(prefix, ":")); strings.HasSuffix(prefix, ":") && isLocalFunction(name) {
+					term.function = name
+					current.Reset()
+				}
+			}
+			inQuotes = !inQuotes
+			quoted = true
+		case inQuotes:
+			current.WriteRune(r)
+		case unicode.IsSpace(r):
+			flush()
+		case r == '|':
+			flush()
+			orPending = true
+		case r == '!' && !started:
+			term.negate = !term.negate
+		default:
+			started = true
+			current.WriteRune(r)
+		}
+	}
+	if inQuotes {
+		return nil, fmt.Errorf("查询中的引号不匹配")
+	}
+	flush()
+
+	var groups [][]rawTerm
+	for _, t := range terms {
+		if t.or {
+			groups[len(groups)-1] = append(groups[len(groups)-1], t)
+			continue
+		}
+		groups = append(groups, []rawTerm{t})
+	}
+	return groups, nil
+}
+
+// localFunctions 本地后端支持的搜索函数
+var localFunctions = map[string]bool{
+	"ext": true, "size": true, "dm": true, "datemodified": true, "dc": true, "datecreated": true,
+	"parent": true, "path": true, "file": true, "files": true, "folder": true, "folders": true,
+	"empty": true, "root": true,
+}
+
+// isLocalFunction 判断是否是支持的搜索函数
+func isLocalFunction(name string) bool {
+	return localFunctions[name]
+}
+
+// parseLocalQuery 将 Everything 搜索语法的子集编译为本地查询
+// 支持: 普通文本和通配符、ext:、size:、dm:/dc:、parent:、path:、file:、folder:、empty:、root:
+// 本地文件系统没有可移植的创建时间，dc: 按修改时间处理
+func parseLocalQuery(query string, opts SearchOptions) (*localQuery, error) {
+	tokens, err := tokenizeLocalQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &localQuery{}
+	for _, group := range tokens {
+		terms := make([]localTerm, 0, len(group))
+		for _, raw := range group {
+			match, err := compileLocalTerm(raw, opts)
+			if err != nil {
+				return nil, err
+			}
+			terms = append(terms, localTerm{negate: raw.negate, match: match})
+		}
+		q.groups = append(q.groups, terms)
+	}
+	return q, nil
+}
+
+// compileLocalTerm 编译单个条件
+func compileLocalTerm(raw rawTerm, opts SearchOptions) (func(*localEntry) bool, error) {
+	switch raw.function {
+	case "":
+		return compileTextMatch(raw.value, opts)
+	case "ext":
+		exts := map[string]bool{}
+		for _, ext := range strings.Split(raw.value, ";") {
+			if ext = strings.TrimPrefix(strings.TrimSpace(ext), "."); ext != "" {
+				exts[strings.ToLower(ext)] = true
+			}
+		}
+		return func(e *localEntry) bool {
+			if e.isDir {
+				return false
+			}
+			return exts[strings.ToLower(strings.TrimPrefix(filepath.Ext(e.name), "."))]
+		}, nil
+	case "size":
+		return compileSizeMatch(raw.value)
+	case "dm", "datemodified", "dc", "datecreated":
+		return compileDateMatch(raw.value, time.Now())
+	case "parent":
+		dir := filepath.Clean(raw.value)
+		return func(e *localEntry) bool {
+			return !e.isRoot && e.parent == dir
+		}, nil
+	case "path":
+		pathOpts := opts
+		pathOpts.MatchPath = true
+		return compileTextMatch(raw.value, pathOpts)
+	case "file", "files":
+		return withValue(raw, opts, func(e *localEntry) bool { return !e.isDir })
+	case "folder", "folders":
+		return withValue(raw, opts, func(e *localEntry) bool { return e.isDir })
+	case "empty":
+		return withValue(raw, opts, func(e *localEntry) bool { return e.isDir && e.children == 0 })
+	case "root":
+		return withValue(raw, opts, func(e *localEntry) bool { return e.isRoot })
+	}
+	return nil, fmt.Errorf("本地后端不支持的搜索函数: %s:", raw.function)
+}
+
+// withValue 处理 file:、folder: 等修饰符：没有值时只按类型过滤，有值时还要匹配文本
+func withValue(raw rawTerm, opts SearchOptions, filter func(*localEntry) bool) (func(*localEntry) bool, error) {
+	if raw.value == "" {
+		return filter, nil
+	}
+	text, err := compileTextMatch(raw.value, opts)
+	if err != nil {
+		return nil, err
+	}
+	return func(e *localEntry) bool { return filter(e) && text(e) }, nil
+}
+
+// compileTextMatch 编译普通文本条件
+// 包含路径分隔符或指定 MatchPath 时匹配完整路径，否则只匹配名称；
+// 包含 * 或 ? 时作为通配符匹配整个名称，否则是子串匹配
+func compileTextMatch(text string, opts SearchOptions) (func(*localEntry) bool, error) {
+	if text == "" {
+		return func(*localEntry) bool { return true }, nil
+	}
+	matchPath := opts.MatchPath || strings.ContainsAny(text, `\/`)
+	subject := func(e *localEntry) string {
+		if matchPath {
+			return e.path
+		}
+		return e.name
+	}
+
+	if strings.ContainsAny(text, "*?") {
+		pattern := "^" + strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(regexp.QuoteMeta(text)) + "$"
+		if !opts.MatchCase {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("无效的通配符: %s", text)
+		}
+		return func(e *localEntry) bool { return re.MatchString(subject(e)) }, nil
+	}
+
+	if opts.MatchWholeWord {
+		pattern := `(^|[^\pL\pN_])` + regexp.QuoteMeta(text) + `($|[^\pL\pN_])`
+		if !opts.MatchCase {
+			pattern = "(?i)" + pattern
+		}
+		re := regexp.MustCompile(pattern)
+		return func(e *localEntry) bool { return re.MatchString(subject(e)) }, nil
+	}
+
+	if opts.MatchCase {
+		return func(e *localEntry) bool { return strings.Contains(subject(e), text) }, nil
+	}
+	lower := strings.ToLower(text)
+	return func(e *localEntry) bool { return strings.Contains(strings.ToLower(subject(e)), lower) }, nil
+}
+
+// sizeUnits 大小单位（与 Everything 一致，按 1024 进位）
+var sizeUnits = map[string]int64{
+	"": 1, "b": 1,
+	"k": 1 << 10, "kb": 1 << 10,
+	"m": 1 << 20, "mb": 1 << 20,
+	"g": 1 << 30, "gb": 1 << 30,
+	"t": 1 << 40, "tb": 1 << 40,
+}
+
+// namedSizes Everything 预定义的大小名称对应的范围 [min, max)，max < 0 表示没有上限
+var namedSizes = map[string][2]int64{
+	"empty":    {0, 1},
+	"tiny":     {0, 10 << 10},
+	"small":    {10 << 10, 100 << 10},
+	"medium":   {100 << 10, 1 << 20},
+	"large":    {1 << 20, 16 << 20},
+	"huge":     {16 << 20, 128 << 20},
+	"gigantic": {128 << 20, -1},
+}
+
+// localSizeValue 数字加可选单位的大小值
+var localSizeValue = regexp.MustCompile(`(?i)^(\d+(?:\.\d+)?)(b|kb|mb|gb|tb|k|m|g|t)?$`)
+
+// parseSizeRange 将大小值解析为范围 [min, max)，max < 0 表示没有上限
+func parseSizeRange(value string) (int64, int64, error) {
+	lower := strings.ToLower(value)
+	if r, ok := namedSizes[lower]; ok {
+		return r[0], r[1], nil
+	}
+	m := localSizeValue.FindStringSubmatch(value)
+	if m == nil {
+		return 0, 0, fmt.Errorf("无效的大小: %s", value)
+	}
+	n, _ := strconv.ParseFloat(m[1], 64)
+	size := int64(n * float64(sizeUnits[strings.ToLower(m[2])]))
+	return size, size + 1, nil
+}
+
+// compileSizeMatch 编译 size: 条件，支持 >、>=、<、<=、= 和 a..b 范围；文件夹不匹配
+func compileSizeMatch(value string) (func(*localEntry) bool, error) {
+	inRange := func(min, max int64) func(*localEntry) bool {
+		return func(e *localEntry) bool {
+			return !e.isDir && e.size >= min && (max < 0 || e.size < max)
+		}
+	}
+
+	if from, to, ok := strings.Cut(value, ".."); ok {
+		min, _, err := parseSizeRange(from)
+		if err != nil {
+			return nil, err
+		}
+		_, max, err := parseSizeRange(to)
+		if err != nil {
+			return nil, err
+		}
+		return inRange(min, max), nil
+	}
+
+	op, operand := splitComparison(value)
+	min, max, err := parseSizeRange(operand)
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case ">":
+		if max < 0 {
+			return func(*localEntry) bool { return false }, nil
+		}
+		return inRange(max, -1), nil
+	case ">=":
+		return inRange(min, -1), nil
+	case "<":
+		return inRange(0, min), nil
+	case "<=":
+		return inRange(0, max), nil
+	}
+	return inRange(min, max), nil
+}
+
+// splitComparison 拆分比较运算符和值，例如 ">=10MB" -> (">=", "10MB")
+func splitComparison(value string) (string, string) {
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(value, op) {
+			return op, value[len(op):]
+		}
+	}
+	return "", value
+}
+
+// localLastPattern lastNdays、lastNweeks 等相对日期
+var localLastPattern = regexp.MustCompile(`^last(\d+)(minute|hour|day|week|month|year)s?$`)
+
+// parseDateRange 将日期值解析为本地时区的范围 [start, end)
+// 支持 today、yesterday、thisweek、lastweek、thismonth、lastmonth、thisyear、lastyear、
+// lastN(minutes|hours|days|weeks|months|years) 以及 YYYY、YYYY-MM、YYYY-MM-DD（分隔符也可以是 / 或 .）
+func parseDateRange(value string, now time.Time) (time.Time, time.Time, error) {
+	lower := strings.ToLower(value)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	weekStart := today.AddDate(0, 0, -int(today.Weekday()))
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	yearStart := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())
+	future := now.Add(100 * 365 * 24 * time.Hour)
+
+	switch lower {
+	case "today":
+		return today, today.AddDate(0, 0, 1), nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), today, nil
+	case "thisweek":
+		return weekStart, weekStart.AddDate(0, 0, 7), nil
+	case "lastweek":
+		return weekStart.AddDate(0, 0, -7), weekStart, nil
+	case "thismonth":
+		return monthStart, monthStart.AddDate(0, 1, 0), nil
+	case "lastmonth":
+		return monthStart.AddDate(0, -1, 0), monthStart, nil
+	case "thisyear":
+		return yearStart, yearStart.AddDate(1, 0, 0), nil
+	case "lastyear":
+		return yearStart.AddDate(-1, 0, 0), yearStart, nil
+	}
+
+	if m := localLastPattern.FindStringSubmatch(lower); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		var start time.Time
+		switch m[2] {
+		case "minute":
+			start = now.Add(-time.Duration(n) * time.Minute)
+		case "hour":
+			start = now.Add(-time.Duration(n) * time.Hour)
+		case "day":
+			start = today.AddDate(0, 0, -n)
+		case "week":
+			start = today.AddDate(0, 0, -7*n)
+		case "month":
+			start = today.AddDate(0, -n, 0)
+		case "year":
+			start = today.AddDate(-n, 0, 0)
+		}
+		return start, future, nil
+	}
+
+	normalized := strings.NewReplacer("/", "-", ".", "-").Replace(value)
+	for _, layout := range []struct {
+		format string
+		years  int
+		months int
+		days   int
+	}{
+		{"2006-1-2", 0, 0, 1},
+		{"2006-1", 0, 1, 0},
+		{"2006", 1, 0, 0},
+	} {
+		if t, err := time.ParseInLocation(layout.format, normalized, now.Location()); err == nil {
+			return t, t.AddDate(layout.years, layout.months, layout.days), nil
+		}
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("无效的日期: %s", value)
+}
+
+// compileDateMatch 编译 dm:/dc: 条件，支持 >、>=、<、<=、= 和 a..b 范围
+func compileDateMatch(value string, now time.Time) (func(*localEntry) bool, error) {
+	between := func(start, end time.Time) func(*localEntry) bool {
+		return func(e *localEntry) bool {
+			return !e.modTime.Before(start) && e.modTime.Before(end)
+		}
+	}
+
+	if from, to, ok := strings.Cut(value, ".."); ok {
+		start, _, err := parseDateRange(from, now)
+		if err != nil {
+			return nil, err
+		}
+		_, end, err := parseDateRange(to, now)
+		if err != nil {
+			return nil, err
+		}
+		return between(start, end), nil
+	}
+
+	op, operand := splitComparison(value)
+	start, end, err := parseDateRange(operand, now)
+	if err != nil {
+		return nil, err
+	}
+	var zero time.Time
+	forever := now.Add(100 * 365 * 24 * time.Hour)
+	switch op {
+	case ">":
+		return between(end, forever), nil
+	case ">=":
+		return between(start, forever), nil
+	case "<":
+		return between(zero, start), nil
+	case "<=":
+		return between(zero, end), nil
+	}
+	return between(start, end), nil
+}
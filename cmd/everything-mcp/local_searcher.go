@@ -0,0 +1,344 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultLocalRescanInterval 本地索引默认的重建间隔
+const defaultLocalRescanInterval = 5 * time.Minute
+
+// localEntry 本地索引中的一个文件或文件夹
+type localEntry struct {
+	path     string // 完整路径
+	name     string // 文件或文件夹名称
+	parent   string // 所在文件夹的完整路径
+	isDir    bool
+	isRoot   bool // 配置的根目录本身
+	size     int64
+	modTime  time.Time
+	mode     fs.FileMode
+	children int // 文件夹的直接子项数量
+}
+
+// LocalSearcher 基于本地文件系统的 EverythingSearcher 实现
+// 启动后第一次搜索时遍历配置的根目录建立内存索引，之后按 rescan 间隔在后台重建；
+// 支持工具使用的 Everything 语法子集，适用于没有 Everything 的 Linux / macOS 环境
+type LocalSearcher struct {
+	roots  []string
+	rescan time.Duration
+
+	buildMu sync.Mutex // 保证同一时间只有一次索引构建
+
+	mu        sync.RWMutex
+	entries   []localEntry
+	indexedAt time.Time
+	building  bool
+}
+
+// NewLocalSearcher 创建本地文件系统搜索后端，rescan <= 0 时使用默认间隔
+func NewLocalSearcher(roots []string, rescan time.Duration) (*LocalSearcher, error) {
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("local 后端需要至少一个根目录")
+	}
+	if rescan <= 0 {
+		rescan = defaultLocalRescanInterval
+	}
+
+	cleaned := make([]string, 0, len(roots))
+	for _, root := range roots {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return nil, fmt.Errorf("无效的根目录 %s: %w", root, err)
+		}
+		info, err := os.Stat(abs)
+		if err != nil {
+			return nil, fmt.Errorf("无法访问根目录 %s: %w", root, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("根目录不是文件夹: %s", root)
+		}
+		cleaned = append(cleaned, abs)
+	}
+
+	return &LocalSearcher{roots: cleaned, rescan: rescan}, nil
+}
+
+// Search 在本地索引中执行搜索
+func (l *LocalSearcher) Search(ctx context.Context, opts SearchOptions) (*SearchResponse, error) {
+	entries, err := l.index(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	match, err := l.compile(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make([]*localEntry, 0)
+	for i := range entries {
+		if i%4096 == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		if match(&entries[i]) {
+			matched = append(matched, &entries[i])
+		}
+	}
+
+	sortLocalEntries(matched, opts.Sort)
+
+	total := len(matched)
+	start := opts.Offset
+	if start > total {
+		start = total
+	}
+	end := total
+	if opts.MaxResults > 0 && start+opts.MaxResults < end {
+		end = start + opts.MaxResults
+	}
+
+	withAttributes := false
+	for _, column := range opts.Columns {
+		if column == "attributes" {
+			withAttributes = true
+		}
+	}
+	results := make([]SearchResult, 0, end-start)
+	for _, e := range matched[start:end] {
+		result := e.result()
+		// 与 EverythingClient 一致，只有请求了 attributes 列时才返回属性
+		if withAttributes {
+			result.Attributes = e.attributes()
+		}
+		results = append(results, result)
+	}
+	return &SearchResponse{Results: results, TotalResults: total}, nil
+}
+
+// compile 将搜索参数编译为匹配函数
+func (l *LocalSearcher) compile(opts SearchOptions) (func(*localEntry) bool, error) {
+	if opts.Regex {
+		pattern := opts.Query
+		if !opts.MatchCase {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("无效的正则表达式: %w", err)
+		}
+		return func(e *localEntry) bool {
+			if opts.MatchPath {
+				return re.MatchString(e.path)
+			}
+			return re.MatchString(e.name)
+		}, nil
+	}
+
+	q, err := parseLocalQuery(opts.Query, opts)
+	if err != nil {
+		return nil, err
+	}
+	return q.match, nil
+}
+
+// result 将索引条目转换为搜索结果，格式与 EverythingClient 返回的一致
+func (e *localEntry) result() SearchResult {
+	result := SearchResult{
+		Path:     e.path,
+		FullPath: e.path,
+		Type:     "file",
+		// 与 EverythingClient 一致，时间统一为 UTC
+		DateModified: e.modTime.UTC(),
+	}
+	if e.isDir {
+		result.Type = "folder"
+	} else {
+		result.Size = e.size
+	}
+	return result
+}
+
+// attributes 按 Unix 的习惯映射为 Windows 文件属性：
+// 以 . 开头的为隐藏，没有写权限的为只读，符号链接为重解析点
+func (e *localEntry) attributes() FileAttributes {
+	attrs := AttributeNormal
+	if e.isDir {
+		attrs = AttributeDirectory
+	}
+	if strings.HasPrefix(e.name, ".") && !e.isRoot {
+		attrs |= AttributeHidden
+	}
+	if e.mode.Perm()&0222 == 0 {
+		attrs |= AttributeReadOnly
+	}
+	if e.mode&fs.ModeSymlink != 0 {
+		attrs |= AttributeReparsePoint
+	}
+	return attrs
+}
+
+// index 返回当前索引；第一次调用时同步构建，过期后在后台重建并继续使用旧索引
+func (l *LocalSearcher) index(ctx context.Context) ([]localEntry, error) {
+	l.mu.RLock()
+	entries, indexedAt, building := l.entries, l.indexedAt, l.building
+	l.mu.RUnlock()
+
+	if indexedAt.IsZero() {
+		// 尚未建立索引：等待构建完成（并发的第一次搜索共享同一次构建）
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			l.rebuild()
+		}()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		l.mu.RLock()
+		defer l.mu.RUnlock()
+		return l.entries, nil
+	}
+
+	if !building && time.Since(indexedAt) > l.rescan {
+		l.mu.Lock()
+		if !l.building {
+			l.building = true
+			go l.rebuild()
+		}
+		l.mu.Unlock()
+	}
+	return entries, nil
+}
+
+// rebuild 遍历所有根目录并替换索引
+func (l *LocalSearcher) rebuild() {
+	l.buildMu.Lock()
+	defer l.buildMu.Unlock()
+
+	// 等待期间其他调用可能已经完成了构建
+	l.mu.RLock()
+	fresh := !l.indexedAt.IsZero() && time.Since(l.indexedAt) <= l.rescan
+	l.mu.RUnlock()
+	if fresh {
+		l.mu.Lock()
+		l.building = false
+		l.mu.Unlock()
+		return
+	}
+
+	entries := make([]localEntry, 0, 1024)
+	for _, root := range l.roots {
+		entries = walkLocalRoot(root, entries)
+	}
+
+	l.mu.Lock()
+	l.entries = entries
+	l.indexedAt = time.Now()
+	l.building = false
+	l.mu.Unlock()
+
+	if os.Getenv("EVERYTHING_DEBUG") == "true" {
+		fmt.Fprintf(os.Stderr, "[DEBUG] 本地索引完成: %d 个条目\n", len(entries))
+	}
+}
+
+// walkLocalRoot 遍历一个根目录，将条目追加到 entries
+// 无法访问的文件夹会被跳过；符号链接不会被跟随
+func walkLocalRoot(root string, entries []localEntry) []localEntry {
+	// 文件夹在 entries 中的位置，用于统计直接子项数量
+	dirIndex := make(map[string]int)
+
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if d != nil && d.IsDir() && path != root {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		entry := localEntry{
+			path:    path,
+			name:    d.Name(),
+			parent:  filepath.Dir(path),
+			isDir:   d.IsDir(),
+			isRoot:  path == root,
+			modTime: info.ModTime(),
+			mode:    info.Mode(),
+		}
+		if entry.isRoot {
+			entry.name = filepath.Base(path)
+		}
+		if !entry.isDir {
+			entry.size = info.Size()
+		}
+
+		if !entry.isRoot {
+			if i, ok := dirIndex[entry.parent]; ok {
+				entries[i].children++
+			}
+		}
+		if entry.isDir {
+			dirIndex[path] = len(entries)
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	return entries
+}
+
+// sortLocalEntries 按 Everything 的排序语义排序，未指定排序字段时按名称升序
+func sortLocalEntries(entries []*localEntry, order SearchSort) {
+	field := order.Field
+	ascending := order.Ascending
+	if field == "" {
+		field, ascending = "name", true
+	}
+
+	compare := func(a, b *localEntry) int {
+		switch field {
+		case "path":
+			if c := strings.Compare(strings.ToLower(a.parent), strings.ToLower(b.parent)); c != 0 {
+				return c
+			}
+		case "size":
+			if a.size != b.size {
+				if a.size < b.size {
+					return -1
+				}
+				return 1
+			}
+		case "date_modified":
+			if !a.modTime.Equal(b.modTime) {
+				if a.modTime.Before(b.modTime) {
+					return -1
+				}
+				return 1
+			}
+		}
+		return strings.Compare(strings.ToLower(a.name), strings.ToLower(b.name))
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		c := compare(entries[i], entries[j])
+		if ascending {
+			return c < 0
+		}
+		return c > 0
+	})
+}
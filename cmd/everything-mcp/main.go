@@ -4,15 +4,22 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
+	"math"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -20,6 +27,12 @@ import (
 	"github.com/mark3labs/mcp-go/server"
 )
 
+// defaultMaxInFlight stdio 模式下默认同时处理的最大请求数
+const defaultMaxInFlight = 8
+
+// defaultMaxResults 工具默认返回的结果数
+const defaultMaxResults = 100
+
 // EverythingConfig 配置 Everything HTTP API 的地址
 type EverythingConfig struct {
 	BaseURL  string
@@ -27,26 +40,114 @@ type EverythingConfig struct {
 	Username string
 	Password string
 	Timeout  time.Duration
+
+	// MaxInFlight stdio 模式下同时处理的最大请求数，<= 0 时使用默认值
+	MaxInFlight int
+
+	// Backend 搜索后端: everything（默认，Everything HTTP API）或 local（本地文件系统索引）
+	Backend string
+	// LocalRoots local 后端索引的根目录
+	LocalRoots []string
+	// LocalRescan local 后端重建索引的间隔，<= 0 时使用默认值
+	LocalRescan time.Duration
+
+	// Backends 联合搜索的命名后端列表；非空时忽略上面的单后端配置
+	Backends []BackendConfig
+
+	// CacheTTL 搜索结果缓存的有效期，<= 0 时不缓存
+	CacheTTL time.Duration
+	// CacheMaxBytes 缓存的最大内存占用，<= 0 时使用默认值
+	CacheMaxBytes int64
+
+	// Retries 连接失败或服务器错误（5xx）时的重试次数，0 表示不重试
+	Retries int
+	// RetryBackoff 第一次重试前的等待时间，之后按指数增长，<= 0 时使用默认值
+	RetryBackoff time.Duration
+	// BreakerThreshold 连续失败多少次后熔断，<= 0 时不启用熔断
+	BreakerThreshold int
+	// BreakerProbe 熔断期间探测后端是否恢复的间隔，<= 0 时使用默认值
+	BreakerProbe time.Duration
+
+	// DisplayTimezone 文本结果中显示时间使用的时区（IANA 名称，例如 Asia/Shanghai），
+	// 为空或 Local 时使用服务器本地时区；结构化结果总是使用 UTC
+	DisplayTimezone string
+
+	// MaxResults 工具未指定 max_results 时返回的结果数
+	MaxResults int
+	// MaxResultsLimit 工具 max_results 的上限，<= 0 时不限制
+	MaxResultsLimit int
+
+	// Tools 启用的工具名称或分组（search、browse、cache、all），为空时启用全部工具
+	Tools []string
+	// DisabledTools 禁用的工具名称或分组，优先于 Tools
+	DisabledTools []string
+	// ToolLimits 按工具名称覆盖 MaxResults 和 MaxResultsLimit
+	ToolLimits map[string]ToolLimits
+
+	// AllowedRoots 允许访问的根目录，为空时不限制
+	AllowedRoots []string
+	// DenyGlobs 隐藏匹配这些规则的路径，见 compileDenyGlob
+	DenyGlobs []string
+
+	// ResourcePollInterval 订阅的搜索结果资源的轮询间隔，<= 0 时使用默认值
+	ResourcePollInterval time.Duration
+
+	// Prompts 配置文件中定义的提示词，与内置提示词同名时替换内置的
+	Prompts []PromptDefinition
 }
 
+// 支持的搜索后端
+const (
+	backendEverything = "everything"
+	backendLocal      = "local"
+)
+
 // DefaultConfig 返回默认配置
 func DefaultConfig() *EverythingConfig {
 	return &EverythingConfig{
-		BaseURL: "http://192.168.7.187",
-		Port:    51780,
-		Timeout: 10 * time.Second,
+		BaseURL:          "http://localhost",
+		Port:             80,
+		Timeout:          10 * time.Second,
+		MaxInFlight:      defaultMaxInFlight,
+		Backend:          backendEverything,
+		Retries:          defaultRetries,
+		RetryBackoff:     defaultRetryBackoff,
+		BreakerThreshold: defaultBreakerThreshold,
+		BreakerProbe:     defaultBreakerProbe,
+		CacheMaxBytes:    defaultCacheMaxBytes,
+		LocalRescan:      defaultLocalRescanInterval,
+		MaxResults:       defaultMaxResults,
+		DenyGlobs:        append([]string(nil), defaultDenyGlobs...),
+
+		ResourcePollInterval: defaultResourcePollInterval,
+	}
+}
+
+// newSearcher 根据配置创建搜索后端
+func newSearcher(config *EverythingConfig) (EverythingSearcher, error) {
+	if len(config.Backends) > 0 {
+		return NewFederatedSearcher(config.Backends, config)
+	}
+	switch config.Backend {
+	case "", backendEverything:
+		return NewEverythingClient(config), nil
+	case backendLocal:
+		return NewLocalSearcher(config.LocalRoots, config.LocalRescan)
 	}
+	return nil, fmt.Errorf("不支持的搜索后端: %s（可选: %s, %s）", config.Backend, backendEverything, backendLocal)
 }
 
 // EverythingSearcher 定义搜索接口，便于测试
 type EverythingSearcher interface {
-	Search(ctx context.Context, query string, maxResults int) ([]SearchResult, error)
+	Search(ctx context.Context, opts SearchOptions) (*SearchResponse, error)
 }
 
 // EverythingClient Everything HTTP API 客户端
 type EverythingClient struct {
 	config *EverythingConfig
 	client *http.Client
+	// breaker 熔断器，BreakerThreshold <= 0 时为 nil
+	breaker *circuitBreaker
 }
 
 // NewEverythingClient 创建新的 Everything 客户端
@@ -54,29 +155,70 @@ func NewEverythingClient(config *EverythingConfig) *EverythingClient {
 	if config == nil {
 		config = DefaultConfig()
 	}
-	return &EverythingClient{
+	c := &EverythingClient{
 		config: config,
 		client: &http.Client{
 			Timeout: config.Timeout,
 		},
 	}
+	c.breaker = newCircuitBreaker(config.BreakerThreshold, config.BreakerProbe, c.probe)
+	return c
+}
+
+// close 停止熔断器的后台探测
+func (c *EverythingClient) close() {
+	c.breaker.close()
+}
+
+// closeSearcher 释放搜索后端的后台资源（例如熔断器的探测），后端不需要释放时不做任何事
+func closeSearcher(searcher EverythingSearcher) {
+	if c, ok := searcher.(interface{ close() }); ok {
+		c.close()
+	}
 }
 
 // SearchResult Everything 搜索结果项
 type SearchResult struct {
 	Path     string `json:"path"`
 	Size     int64  `json:"size,omitempty"`
-	Date     string `json:"date,omitempty"`
 	Type     string `json:"type,omitempty"`
 	FullPath string `json:"full_path,omitempty"`
+	// Source 联合搜索时结果所属的后端名称
+	Source string `json:"source,omitempty"`
+
+	// 所有时间均为 UTC，零值表示未知（序列化为 0001-01-01T00:00:00Z）；显示时再转换为 DisplayTimezone
+	DateModified time.Time `json:"date_modified"`
+	// 以下字段只有请求了对应的列（见 fileInfoColumns）时才有值
+	DateCreated  time.Time      `json:"date_created"`
+	DateAccessed time.Time      `json:"date_accessed"`
+	DateRun      time.Time      `json:"date_run"` // 最近一次通过 Everything 打开的时间
+	Attributes   FileAttributes `json:"attributes,omitempty"`
 }
 
-// parseWindowsFileTime 将 Windows FILETIME 格式转换为可读的日期字符串
-// FILETIME 是从 1601-01-01 00:00:00 UTC 开始的 100 纳秒间隔数
-func parseWindowsFileTime(filetimeStr string) string {
-	filetime, err := strconv.ParseInt(filetimeStr, 10, 64)
-	if err != nil {
-		return ""
+// SearchResponse 一次搜索的结果
+type SearchResponse struct {
+	Results []SearchResult
+	// TotalResults 匹配的结果总数（不受 offset 和 maxResults 影响）
+	TotalResults int
+	// Warnings 不影响其余结果的问题，例如联合搜索中某个后端失败
+	Warnings []string
+	// Redacted 本页中被访问策略隐藏的结果数；翻页时这些结果仍占用位置
+	Redacted int
+}
+
+// fileTimeToTime 将 Windows FILETIME 转换为 UTC 时间，保留 100 纳秒精度
+// FILETIME 是从 1601-01-01 00:00:00 UTC 开始的 100 纳秒间隔数，与时区无关。
+// Everything 用 0 和 0xFFFFFFFFFFFFFFFF（有时写作 -1）表示未知日期，
+// 这些值以及无法解析的值返回零值
+func fileTimeToTime(filetimeStr string) time.Time {
+	filetimeStr = strings.TrimSpace(filetimeStr)
+	if filetimeStr == "" || filetimeStr == "-1" {
+		return time.Time{}
+	}
+	filetime, err := strconv.ParseUint(filetimeStr, 10, 64)
+	// 有效的 FILETIME 不超过 0x7FFFFFFFFFFFFFFF，更大的值都是哨兵值
+	if err != nil || filetime == 0 || filetime > math.MaxInt64 {
+		return time.Time{}
 	}
 
 	// Windows FILETIME epoch: 1601-01-01
@@ -84,18 +226,27 @@ func parseWindowsFileTime(filetimeStr string) string {
 	// 两者相差 116444736000000000 个 100 纳秒间隔
 	const windowsEpochDiff = 116444736000000000
 
-	// 转换为 Unix 时间戳（秒）
-	unixTime := (filetime - windowsEpochDiff) / 10000000
+	// 分别转换秒和余下的 100 纳秒间隔，避免换算成纳秒时溢出
+	ticks := int64(filetime) - windowsEpochDiff
+	return time.Unix(ticks/10000000, ticks%10000000*100).UTC()
+}
 
-	// 转换为 Go time.Time
-	t := time.Unix(unixTime, 0)
+// jsonScalar 接受 JSON 字符串或数字，Everything 不同版本对数值列的编码不一致
+type jsonScalar string
 
-	// 格式化为可读的日期时间字符串
-	return t.Format("2006-01-02 15:04:05")
+// UnmarshalJSON 去掉字符串的引号，数字按原样保留
+func (v *jsonScalar) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*v = jsonScalar(s)
+		return nil
+	}
+	*v = jsonScalar(strings.TrimSpace(string(data)))
+	return nil
 }
 
-// Search 执行文件搜索
-func (c *EverythingClient) Search(ctx context.Context, query string, maxResults int) ([]SearchResult, error) {
+// baseURL 返回 Everything HTTP 服务器的地址（包括端口）
+func (c *EverythingClient) baseURL() string {
 	var baseURL string
 	// 如果 BaseURL 已经包含协议（http:// 或 https://），直接使用
 	if strings.HasPrefix(c.config.BaseURL, "http://") || strings.HasPrefix(c.config.BaseURL, "https://") {
@@ -110,80 +261,89 @@ func (c *EverythingClient) Search(ctx context.Context, query string, maxResults
 	} else {
 		baseURL = fmt.Sprintf("%s:%d", c.config.BaseURL, c.config.Port)
 	}
+	return baseURL
+}
 
-	// Everything HTTP API 使用 /?search= 参数
-	params := url.Values{}
-	params.Add("search", query)
-	params.Add("json", "1")                 // 请求 JSON 格式输出
-	params.Add("path_column", "1")          // 获取路径信息
-	params.Add("size_column", "1")          // 获取文件大小
-	params.Add("date_modified_column", "1") // 获取修改日期
-	if maxResults > 0 {
-		params.Add("count", fmt.Sprintf("%d", maxResults)) // Everything 使用 count 参数限制结果数量
-	}
-
-	searchURL := fmt.Sprintf("%s/?%s", baseURL, params.Encode())
-
-	req, err := http.NewRequestWithContext(ctx, "GET", searchURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("创建请求失败: %w", err)
+// probe 发送一个只取一条结果的请求，检查后端是否恢复
+// 后端有响应（即使是认证失败）就认为已经恢复
+func (c *EverythingClient) probe(ctx context.Context) error {
+	probeURL := fmt.Sprintf("%s/?%s", c.baseURL(), url.Values{"json": {"1"}, "count": {"1"}}.Encode())
+	_, err := c.attempt(ctx, probeURL)
+	var backendErr *backendError
+	if errors.As(err, &backendErr) {
+		return err
 	}
+	return nil
+}
 
-	// 如果配置了用户名和密码，添加 HTTP Basic Auth
-	// 只有当用户名和密码都不为空时才添加认证头
-	if c.config.Username != "" && c.config.Password != "" {
-		req.SetBasicAuth(c.config.Username, c.config.Password)
+// Search 执行文件搜索
+func (c *EverythingClient) Search(ctx context.Context, opts SearchOptions) (*SearchResponse, error) {
+	baseURL := c.baseURL()
 
-		// 调试：输出认证信息（仅在调试模式下）
-		if os.Getenv("EVERYTHING_DEBUG") == "true" {
-			authHeader := req.Header.Get("Authorization")
-			fmt.Fprintf(os.Stderr, "[DEBUG] 请求 URL: %s\n", searchURL)
-			fmt.Fprintf(os.Stderr, "[DEBUG] 用户名: %s\n", c.config.Username)
-			fmt.Fprintf(os.Stderr, "[DEBUG] 密码长度: %d\n", len(c.config.Password))
-			fmt.Fprintf(os.Stderr, "[DEBUG] Authorization 头: %s\n", authHeader)
-		}
-	} else {
-		// 如果用户名或密码为空，但仍然收到 401 错误，说明服务器需要认证
-		// 这种情况下，我们应该返回一个更明确的错误信息
-		if os.Getenv("EVERYTHING_DEBUG") == "true" {
-			fmt.Fprintf(os.Stderr, "[DEBUG] 警告: 未设置认证信息\n")
+	// Everything HTTP API 使用 /?search= 参数
+	params := url.Values{}
+	params.Add("search", opts.Query)
+	params.Add("json", "1")        // 请求 JSON 格式输出
+	params.Add("path_column", "1") // 获取路径信息
+	columns := opts.Columns
+	if len(columns) == 0 {
+		columns = defaultColumns
+	}
+	for _, column := range columns {
+		params.Add(column+"_column", "1") // 例如 size_column、date_modified_column
+	}
+	if opts.Offset > 0 {
+		params.Add("offset", fmt.Sprintf("%d", opts.Offset)) // 跳过前 offset 个结果，用于分页
+	}
+	if opts.MaxResults > 0 {
+		params.Add("count", fmt.Sprintf("%d", opts.MaxResults)) // Everything 使用 count 参数限制结果数量
+	}
+	if opts.Sort.Field != "" {
+		params.Add("sort", opts.Sort.Field) // 由 Everything 服务器端排序
+		if opts.Sort.Ascending {
+			params.Add("ascending", "1")
+		} else {
+			params.Add("ascending", "0")
 		}
 	}
-
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("请求失败: %w", err)
+	// 搜索选项
+	if opts.MatchCase {
+		params.Add("case", "1")
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		// 如果是 401 错误，提供更详细的错误信息
-		if resp.StatusCode == http.StatusUnauthorized {
-			hasAuth := c.config.Username != "" && c.config.Password != ""
-			if !hasAuth {
-				return nil, fmt.Errorf("HTTP 错误 401: 服务器需要认证，但未提供用户名和密码。请设置 EVERYTHING_USERNAME 和 EVERYTHING_PASSWORD 环境变量")
-			}
-			return nil, fmt.Errorf("HTTP 错误 401: 认证失败。请检查用户名和密码是否正确（当前用户名: %s）", c.config.Username)
-		}
-		return nil, fmt.Errorf("HTTP 错误 %d: %s", resp.StatusCode, string(body))
+	if opts.MatchWholeWord {
+		params.Add("wholeword", "1")
+	}
+	if opts.MatchPath {
+		params.Add("path", "1")
+	}
+	if opts.MatchDiacritics {
+		params.Add("diacritics", "1")
+	}
+	if opts.Regex {
+		params.Add("regex", "1")
 	}
 
+	searchURL := fmt.Sprintf("%s/?%s", baseURL, params.Encode())
+
 	// Everything HTTP API 返回 JSON 格式（因为我们添加了 json=1 参数）
-	body, err := io.ReadAll(resp.Body)
+	body, err := c.get(ctx, searchURL)
 	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %w", err)
+		return nil, err
 	}
 
 	// 尝试解析为 JSON 格式
 	var jsonResponse struct {
 		TotalResults int `json:"totalResults"`
 		Results      []struct {
-			Type         string `json:"type"`
-			Name         string `json:"name"`
-			Path         string `json:"path"`
-			Size         string `json:"size,omitempty"`          // 字符串格式的字节数
-			DateModified string `json:"date_modified,omitempty"` // Windows FILETIME 格式
+			Type         string     `json:"type"`
+			Name         string     `json:"name"`
+			Path         string     `json:"path"`
+			Size         string     `json:"size,omitempty"`          // 字符串格式的字节数
+			DateModified jsonScalar `json:"date_modified,omitempty"` // Windows FILETIME 格式
+			DateCreated  jsonScalar `json:"date_created,omitempty"`
+			DateAccessed jsonScalar `json:"date_accessed,omitempty"`
+			DateRun      jsonScalar `json:"date_run,omitempty"`
+			Attributes   jsonScalar `json:"attributes,omitempty"` // FILE_ATTRIBUTE_* 位的十进制值
 		} `json:"results"`
 	}
 
@@ -200,7 +360,7 @@ func (c *EverythingClient) Search(ctx context.Context, query string, maxResults
 				})
 			}
 		}
-		return results, nil
+		return &SearchResponse{Results: results, TotalResults: opts.Offset + len(results)}, nil
 	}
 
 	// 解析 JSON 结果
@@ -222,22 +382,109 @@ func (c *EverythingClient) Search(ctx context.Context, query string, maxResults
 			}
 		}
 
-		// 解析修改日期（Windows FILETIME 格式）
-		var dateStr string
-		if item.DateModified != "" {
-			dateStr = parseWindowsFileTime(item.DateModified)
-		}
-
-		results = append(results, SearchResult{
+		result := SearchResult{
 			Path:     fullPath,
 			Type:     item.Type,
 			Size:     size,
-			Date:     dateStr,
 			FullPath: fullPath,
-		})
+		}
+		// 解析日期（Windows FILETIME 格式）
+		if item.DateModified != "" {
+			result.DateModified = fileTimeToTime(string(item.DateModified))
+		}
+		if item.DateCreated != "" {
+			result.DateCreated = fileTimeToTime(string(item.DateCreated))
+		}
+		if item.DateAccessed != "" {
+			result.DateAccessed = fileTimeToTime(string(item.DateAccessed))
+		}
+		if item.DateRun != "" {
+			result.DateRun = fileTimeToTime(string(item.DateRun))
+		}
+		if item.Attributes != "" {
+			if attrs, err := strconv.ParseUint(string(item.Attributes), 10, 32); err == nil {
+				result.Attributes = FileAttributes(attrs)
+			}
+		}
+		results = append(results, result)
+	}
+
+	// 防御性截断，保证结果数与分页位置一致
+	if opts.MaxResults > 0 && len(results) > opts.MaxResults {
+		results = results[:opts.MaxResults]
 	}
 
-	return results, nil
+	return &SearchResponse{Results: results, TotalResults: jsonResponse.TotalResults}, nil
+}
+
+// attempt 发送一次 GET 请求并返回响应内容
+// 后端不可达或服务器端错误返回 *backendError，其余错误（例如认证失败）说明后端可用
+func (c *EverythingClient) attempt(ctx context.Context, searchURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", searchURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	// 如果配置了用户名和密码，添加 HTTP Basic Auth
+	// 只有当用户名和密码都不为空时才添加认证头
+	if c.config.Username != "" && c.config.Password != "" {
+		req.SetBasicAuth(c.config.Username, c.config.Password)
+
+		// 调试：输出认证信息（仅在调试模式下）
+		if os.Getenv("EVERYTHING_DEBUG") == "true" {
+			authHeader := req.Header.Get("Authorization")
+			fmt.Fprintf(os.Stderr, "[DEBUG] 请求 URL: %s\n", searchURL)
+			fmt.Fprintf(os.Stderr, "[DEBUG] 用户名: %s\n", c.config.Username)
+			fmt.Fprintf(os.Stderr, "[DEBUG] 密码长度: %d\n", len(c.config.Password))
+			fmt.Fprintf(os.Stderr, "[DEBUG] Authorization 头: %s\n", authHeader)
+		}
+	} else {
+		// 如果用户名或密码为空，但仍然收到 401 错误，说明服务器需要认证
+		// 这种情况下，我们应该返回一个更明确的错误信息
+		if os.Getenv("EVERYTHING_DEBUG") == "true" {
+			fmt.Fprintf(os.Stderr, "[DEBUG] 警告: 未设置认证信息\n")
+		}
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		// 连接被拒绝或重置可以重试；超时已经用完了整个等待时间，不再重试
+		var netErr net.Error
+		timeout := errors.As(err, &netErr) && netErr.Timeout()
+		return nil, &backendError{err: fmt.Errorf("请求失败: %w", err), retry: !timeout}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		// 如果是 401 错误，提供更详细的错误信息
+		if resp.StatusCode == http.StatusUnauthorized {
+			hasAuth := c.config.Username != "" && c.config.Password != ""
+			if !hasAuth {
+				return nil, fmt.Errorf("HTTP 错误 401: 服务器需要认证，但未提供用户名和密码。请设置 EVERYTHING_USERNAME 和 EVERYTHING_PASSWORD 环境变量")
+			}
+			return nil, fmt.Errorf("HTTP 错误 401: 认证失败。请检查用户名和密码是否正确（当前用户名: %s）", c.config.Username)
+		}
+		err := fmt.Errorf("HTTP 错误 %d: %s", resp.StatusCode, string(body))
+		// 5xx 和 429 是服务器端的临时问题，可以重试
+		if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+			return nil, &backendError{err: err, retry: true}
+		}
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, &backendError{err: fmt.Errorf("读取响应失败: %w", err), retry: true}
+	}
+	return body, nil
+
 }
 
 // MCPEverythingServer MCP 服务器
@@ -245,35 +492,218 @@ type MCPEverythingServer struct {
 	server *server.DefaultServer
 	client EverythingSearcher
 	config *EverythingConfig
+	// cache 启用缓存时的缓存层（也是 client），用于 cache_stats 工具
+	cache *CachingSearcher
+	// location 文本结果显示时间使用的时区
+	location *time.Location
+	// tools 启用的工具，其余工具不出现在 tools/list 中，调用时返回错误
+	tools toolSet
+	// policy 路径访问策略（已应用在 client 上），nil 表示不限制
+	policy *PathPolicy
+	// prompts 内置和配置文件中定义的提示词
+	prompts []PromptDefinition
+	// subscriptions 资源订阅，由 reloadingServer 创建并在重新加载后沿用；nil 时不支持订阅
+	subscriptions *resourceSubscriptions
+	// federated 联合搜索的后端集合，用于读取 everything://<后端名称>/ 资源；单后端时为 nil
+	federated *FederatedSearcher
+	// backend 未经缓存和访问策略包装的搜索后端，close 时释放
+	backend EverythingSearcher
+}
+
+// close 释放服务器的后台资源，重新加载配置后由 reloadingServer 对旧的服务器调用
+func (s *MCPEverythingServer) close() {
+	closeSearcher(s.backend)
 }
 
 // NewMCPEverythingServer 创建新的 MCP Everything 服务器
-func NewMCPEverythingServer(config *EverythingConfig) *MCPEverythingServer {
+func NewMCPEverythingServer(config *EverythingConfig) (*MCPEverythingServer, error) {
 	if config == nil {
 		config = DefaultConfig()
 	}
 
 	mcpServer := server.NewDefaultServer("everything-mcp", "1.0.0")
 
-	everythingClient := NewEverythingClient(config)
+	location, err := loadDisplayLocation(config.DisplayTimezone)
+	if err != nil {
+		return nil, err
+	}
 
-	s := &MCPEverythingServer{
-		server: mcpServer,
-		client: everythingClient,
-		config: config,
+	tools, err := newToolSet(config)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := NewPathPolicy(config.AllowedRoots, config.DenyGlobs)
+	if err != nil {
+		return nil, err
+	}
+
+	prompts, err := newPromptSet(config)
+	if err != nil {
+		return nil, err
 	}
 
-	// 注册工具处理器
-	mcpServer.HandleListTools(s.handleListTools)
-	mcpServer.HandleCallTool(s.handleCallTool)
+	searcher, err := newSearcher(config)
+	if err != nil {
+		return nil, err
+	}
 
-	// 注册自定义初始化处理器，声明 tools capability
+	s := &MCPEverythingServer{
+		server:   mcpServer,
+		client:   searcher,
+		backend:  searcher,
+		config:   config,
+		location: location,
+		tools:    tools,
+		policy:   policy,
+		prompts:  prompts,
+	}
+	if f, ok := searcher.(*FederatedSearcher); ok {
+		s.federated = f
+	}
+	if config.CacheTTL > 0 {
+		s.cache = NewCachingSearcher(searcher, config.CacheTTL, config.CacheMaxBytes)
+		s.client = s.cache
+	}
+	// 访问策略在缓存外层，缓存中保存的是限制在根目录中的查询结果
+	if policy != nil {
+		s.client = NewPolicySearcher(s.client, policy)
+	}
+
+	// 注册自定义初始化处理器，声明 tools、resources 和 prompts capability
+	// 工具相关的请求由 Request 直接处理，见下方说明
 	mcpServer.HandleInitialize(s.handleInitialize)
 
-	return s
+	return s, nil
+}
+
+// requestHandler 处理一个 JSON-RPC 请求并返回结果
+type requestHandler interface {
+	Request(ctx context.Context, method string, params json.RawMessage) (interface{}, error)
+}
+
+// Request 处理一个 JSON-RPC 请求
+// mcp-go v0.1.0 的工具类型不支持 outputSchema 和 structuredContent，也没有资源模板，
+// 因此工具、资源、提示词和补全相关的请求由本服务器直接处理；initialize 和 ping 交给 mcp-go，
+// 其他方法返回 -32601
+func (s *MCPEverythingServer) Request(
+	ctx context.Context,
+	method string,
+	params json.RawMessage,
+) (interface{}, error) {
+	if params == nil {
+		params = json.RawMessage("{}")
+	}
+
+	switch method {
+	case "tools/list":
+		var p struct {
+			Cursor *string `json:"cursor,omitempty"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, invalidParams("无效的参数: %v", err)
+		}
+		return s.handleListTools(ctx, p.Cursor)
+
+	case "tools/call":
+		var p struct {
+			Name      string                 `json:"name"`
+			Arguments map[string]interface{} `json:"arguments,omitempty"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, invalidParams("无效的参数: %v", err)
+		}
+		if p.Name == "" {
+			return nil, invalidParams("缺少参数 name")
+		}
+		return s.handleCallTool(ctx, p.Name, p.Arguments)
+
+	case "resources/list":
+		return s.handleListResources(ctx)
+
+	case "resources/templates/list":
+		return s.handleListResourceTemplates(), nil
+
+	case "resources/read":
+		var p struct {
+			URI string `json:"uri"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, invalidParams("无效的参数: %v", err)
+		}
+		if p.URI == "" {
+			return nil, invalidParams("缺少参数 uri")
+		}
+		return s.handleReadResource(ctx, p.URI)
+
+	case "resources/subscribe", "resources/unsubscribe":
+		sub := subscriberFromContext(ctx)
+		if s.subscriptions == nil || sub == nil {
+			break
+		}
+		var p struct {
+			URI string `json:"uri"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, invalidParams("无效的参数: %v", err)
+		}
+		if p.URI == "" {
+			return nil, invalidParams("缺少参数 uri")
+		}
+		if method == "resources/unsubscribe" {
+			s.subscriptions.unsubscribe(sub, p.URI)
+			return struct{}{}, nil
+		}
+		if err := s.subscriptions.subscribe(ctx, sub, p.URI); err != nil {
+			return nil, err
+		}
+		return struct{}{}, nil
+
+	case "prompts/list":
+		return s.handleListPrompts(), nil
+
+	case "prompts/get":
+		var p struct {
+			Name      string            `json:"name"`
+			Arguments map[string]string `json:"arguments,omitempty"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, invalidParams("无效的参数: %v", err)
+		}
+		if p.Name == "" {
+			return nil, invalidParams("缺少参数 name")
+		}
+		return s.handleGetPrompt(p.Name, p.Arguments)
+
+	case "completion/complete":
+		var p struct {
+			Ref      CompletionReference  `json:"ref"`
+			Argument mcp.CompleteArgument `json:"argument"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, invalidParams("无效的参数: %v", err)
+		}
+		if p.Argument.Name == "" {
+			return nil, invalidParams("缺少参数 argument.name")
+		}
+		return s.handleComplete(ctx, p.Ref, p.Argument)
+
+	case "initialize", "ping":
+		result, err := s.server.Request(ctx, method, params)
+		if err != nil {
+			return nil, mcpGoError(err)
+		}
+		if method == "initialize" {
+			return withCompletions(result), nil
+		}
+		return result, nil
+	}
+
+	// mcp-go 对未声明的 capability 也会返回空结果，这里统一报告方法不存在
+	return nil, newRPCError(codeMethodNotFound, "未知的方法: %s", method)
 }
 
-// handleInitialize 处理初始化请求，声明 tools capability
+// handleInitialize 处理初始化请求，声明 tools、resources 和 prompts capability
 func (s *MCPEverythingServer) handleInitialize(
 	ctx context.Context,
 	capabilities mcp.ClientCapabilities,
@@ -299,6 +729,17 @@ func (s *MCPEverythingServer) handleInitialize(
 			}{
 				ListChanged: true,
 			},
+			Prompts: &struct {
+				ListChanged bool `json:"listChanged"`
+			}{
+				ListChanged: true,
+			},
+			Resources: &struct {
+				ListChanged bool `json:"listChanged"`
+				Subscribe   bool `json:"subscribe"`
+			}{
+				Subscribe: s.subscriptions != nil,
+			},
 		},
 	}, nil
 }
@@ -307,9 +748,9 @@ func (s *MCPEverythingServer) handleInitialize(
 func (s *MCPEverythingServer) handleListTools(
 	ctx context.Context,
 	cursor *string,
-) (*mcp.ListToolsResult, error) {
-	return &mcp.ListToolsResult{
-		Tools: []mcp.Tool{
+) (*ListToolsResult, error) {
+	result := &ListToolsResult{
+		Tools: []Tool{
 			{
 				Name:        "search_files",
 				Description: "搜索文件和文件夹。支持文件名、路径、扩展名等多种搜索方式。返回结果包含：路径、类型(file/folder)、大小、修改时间。",
@@ -325,8 +766,44 @@ func (s *MCPEverythingServer) handleListTools(
 							"description": "最大返回结果数量，默认 100",
 							"default":     100,
 						},
+						"offset": map[string]interface{}{
+							"type":        "integer",
+							"description": "跳过前 N 个结果，用于分页，默认 0",
+							"default":     0,
+						},
+						"match_case": map[string]interface{}{
+							"type":        "boolean",
+							"description": "区分大小写，默认 false",
+						},
+						"match_whole_word": map[string]interface{}{
+							"type":        "boolean",
+							"description": "全字匹配，默认 false",
+						},
+						"match_path": map[string]interface{}{
+							"type":        "boolean",
+							"description": "匹配完整路径而不仅是文件名，默认 false",
+						},
+						"match_diacritics": map[string]interface{}{
+							"type":        "boolean",
+							"description": "区分变音符号（例如 é 与 e），默认 false",
+						},
+						"cursor": map[string]interface{}{
+							"type":        "string",
+							"description": "上一页返回的 next_cursor，用于获取下一页（优先于 offset）",
+						},
+						"sort": map[string]interface{}{
+							"type":        "string",
+							"description": "排序字段: name, path, size, date_modified，默认按名称",
+							"enum":        []string{"name", "path", "size", "date_modified"},
+						},
+						"order": map[string]interface{}{
+							"type":        "string",
+							"description": "排序方向: asc 或 desc；未指定时 size 和 date_modified 降序，name 和 path 升序",
+							"enum":        []string{"asc", "desc"},
+						},
 					},
 				},
+				OutputSchema: searchOutputSchema,
 			},
 			{
 				Name:        "search_by_extension",
@@ -343,8 +820,28 @@ func (s *MCPEverythingServer) handleListTools(
 							"description": "最大返回结果数量，默认 100",
 							"default":     100,
 						},
+						"offset": map[string]interface{}{
+							"type":        "integer",
+							"description": "跳过前 N 个结果，用于分页，默认 0",
+							"default":     0,
+						},
+						"cursor": map[string]interface{}{
+							"type":        "string",
+							"description": "上一页返回的 next_cursor，用于获取下一页（优先于 offset）",
+						},
+						"sort": map[string]interface{}{
+							"type":        "string",
+							"description": "排序字段: name, path, size, date_modified，默认按名称",
+							"enum":        []string{"name", "path", "size", "date_modified"},
+						},
+						"order": map[string]interface{}{
+							"type":        "string",
+							"description": "排序方向: asc 或 desc；未指定时 size 和 date_modified 降序，name 和 path 升序",
+							"enum":        []string{"asc", "desc"},
+						},
 					},
 				},
+				OutputSchema: searchOutputSchema,
 			},
 			{
 				Name:        "search_by_path",
@@ -365,8 +862,44 @@ func (s *MCPEverythingServer) handleListTools(
 							"description": "最大返回结果数量，默认 100",
 							"default":     100,
 						},
+						"offset": map[string]interface{}{
+							"type":        "integer",
+							"description": "跳过前 N 个结果，用于分页，默认 0",
+							"default":     0,
+						},
+						"match_case": map[string]interface{}{
+							"type":        "boolean",
+							"description": "区分大小写，默认 false",
+						},
+						"match_whole_word": map[string]interface{}{
+							"type":        "boolean",
+							"description": "全字匹配，默认 false",
+						},
+						"match_path": map[string]interface{}{
+							"type":        "boolean",
+							"description": "匹配完整路径而不仅是文件名，默认 false",
+						},
+						"match_diacritics": map[string]interface{}{
+							"type":        "boolean",
+							"description": "区分变音符号（例如 é 与 e），默认 false",
+						},
+						"cursor": map[string]interface{}{
+							"type":        "string",
+							"description": "上一页返回的 next_cursor，用于获取下一页（优先于 offset）",
+						},
+						"sort": map[string]interface{}{
+							"type":        "string",
+							"description": "排序字段: name, path, size, date_modified，默认按名称",
+							"enum":        []string{"name", "path", "size", "date_modified"},
+						},
+						"order": map[string]interface{}{
+							"type":        "string",
+							"description": "排序方向: asc 或 desc；未指定时 size 和 date_modified 降序，name 和 path 升序",
+							"enum":        []string{"asc", "desc"},
+						},
 					},
 				},
+				OutputSchema: searchOutputSchema,
 			},
 			{
 				Name:        "search_by_size",
@@ -391,8 +924,28 @@ func (s *MCPEverythingServer) handleListTools(
 							"description": "最大返回结果数量，默认 100",
 							"default":     100,
 						},
+						"offset": map[string]interface{}{
+							"type":        "integer",
+							"description": "跳过前 N 个结果，用于分页，默认 0",
+							"default":     0,
+						},
+						"cursor": map[string]interface{}{
+							"type":        "string",
+							"description": "上一页返回的 next_cursor，用于获取下一页（优先于 offset）",
+						},
+						"sort": map[string]interface{}{
+							"type":        "string",
+							"description": "排序字段: name, path, size, date_modified，默认 size",
+							"enum":        []string{"name", "path", "size", "date_modified"},
+						},
+						"order": map[string]interface{}{
+							"type":        "string",
+							"description": "排序方向: asc 或 desc；未指定时 size 和 date_modified 降序，name 和 path 升序",
+							"enum":        []string{"asc", "desc"},
+						},
 					},
 				},
+				OutputSchema: searchOutputSchema,
 			},
 			{
 				Name:        "search_by_date",
@@ -423,8 +976,28 @@ func (s *MCPEverythingServer) handleListTools(
 							"description": "最大返回结果数量，默认 100",
 							"default":     100,
 						},
+						"offset": map[string]interface{}{
+							"type":        "integer",
+							"description": "跳过前 N 个结果，用于分页，默认 0",
+							"default":     0,
+						},
+						"cursor": map[string]interface{}{
+							"type":        "string",
+							"description": "上一页返回的 next_cursor，用于获取下一页（优先于 offset）",
+						},
+						"sort": map[string]interface{}{
+							"type":        "string",
+							"description": "排序字段: name, path, size, date_modified，默认 date_modified",
+							"enum":        []string{"name", "path", "size", "date_modified"},
+						},
+						"order": map[string]interface{}{
+							"type":        "string",
+							"description": "排序方向: asc 或 desc；未指定时 size 和 date_modified 降序，name 和 path 升序",
+							"enum":        []string{"asc", "desc"},
+						},
 					},
 				},
+				OutputSchema: searchOutputSchema,
 			},
 			{
 				Name:        "search_recent_files",
@@ -446,8 +1019,28 @@ func (s *MCPEverythingServer) handleListTools(
 							"description": "最大返回结果数量，默认 100",
 							"default":     100,
 						},
+						"offset": map[string]interface{}{
+							"type":        "integer",
+							"description": "跳过前 N 个结果，用于分页，默认 0",
+							"default":     0,
+						},
+						"cursor": map[string]interface{}{
+							"type":        "string",
+							"description": "上一页返回的 next_cursor，用于获取下一页（优先于 offset）",
+						},
+						"sort": map[string]interface{}{
+							"type":        "string",
+							"description": "排序字段: name, path, size, date_modified，默认 date_modified",
+							"enum":        []string{"name", "path", "size", "date_modified"},
+						},
+						"order": map[string]interface{}{
+							"type":        "string",
+							"description": "排序方向: asc 或 desc；未指定时 size 和 date_modified 降序，name 和 path 升序",
+							"enum":        []string{"asc", "desc"},
+						},
 					},
 				},
+				OutputSchema: searchOutputSchema,
 			},
 			{
 				Name:        "search_large_files",
@@ -462,15 +1055,35 @@ func (s *MCPEverythingServer) handleListTools(
 						},
 						"path": map[string]interface{}{
 							"type":        "string",
-							"description": "搜索路径（可选）",
+							"description": "只搜索该路径下的文件（可选）",
 						},
 						"max_results": map[string]interface{}{
 							"type":        "integer",
 							"description": "最大返回结果数量，默认 100",
 							"default":     100,
 						},
+						"offset": map[string]interface{}{
+							"type":        "integer",
+							"description": "跳过前 N 个结果，用于分页，默认 0",
+							"default":     0,
+						},
+						"cursor": map[string]interface{}{
+							"type":        "string",
+							"description": "上一页返回的 next_cursor，用于获取下一页（优先于 offset）",
+						},
+						"sort": map[string]interface{}{
+							"type":        "string",
+							"description": "排序字段: name, path, size, date_modified，默认 size",
+							"enum":        []string{"name", "path", "size", "date_modified"},
+						},
+						"order": map[string]interface{}{
+							"type":        "string",
+							"description": "排序方向: asc 或 desc；未指定时 size 和 date_modified 降序，name 和 path 升序",
+							"enum":        []string{"asc", "desc"},
+						},
 					},
 				},
+				OutputSchema: searchOutputSchema,
 			},
 			{
 				Name:        "search_empty_files",
@@ -493,8 +1106,28 @@ func (s *MCPEverythingServer) handleListTools(
 							"description": "最大返回结果数量，默认 100",
 							"default":     100,
 						},
+						"offset": map[string]interface{}{
+							"type":        "integer",
+							"description": "跳过前 N 个结果，用于分页，默认 0",
+							"default":     0,
+						},
+						"cursor": map[string]interface{}{
+							"type":        "string",
+							"description": "上一页返回的 next_cursor，用于获取下一页（优先于 offset）",
+						},
+						"sort": map[string]interface{}{
+							"type":        "string",
+							"description": "排序字段: name, path, size, date_modified，默认按名称",
+							"enum":        []string{"name", "path", "size", "date_modified"},
+						},
+						"order": map[string]interface{}{
+							"type":        "string",
+							"description": "排序方向: asc 或 desc；未指定时 size 和 date_modified 降序，name 和 path 升序",
+							"enum":        []string{"asc", "desc"},
+						},
 					},
 				},
+				OutputSchema: searchOutputSchema,
 			},
 			{
 				Name:        "search_by_content_type",
@@ -516,8 +1149,28 @@ func (s *MCPEverythingServer) handleListTools(
 							"description": "最大返回结果数量，默认 100",
 							"default":     100,
 						},
+						"offset": map[string]interface{}{
+							"type":        "integer",
+							"description": "跳过前 N 个结果，用于分页，默认 0",
+							"default":     0,
+						},
+						"cursor": map[string]interface{}{
+							"type":        "string",
+							"description": "上一页返回的 next_cursor，用于获取下一页（优先于 offset）",
+						},
+						"sort": map[string]interface{}{
+							"type":        "string",
+							"description": "排序字段: name, path, size, date_modified，默认按名称",
+							"enum":        []string{"name", "path", "size", "date_modified"},
+						},
+						"order": map[string]interface{}{
+							"type":        "string",
+							"description": "排序方向: asc 或 desc；未指定时 size 和 date_modified 降序，name 和 path 升序",
+							"enum":        []string{"asc", "desc"},
+						},
 					},
 				},
+				OutputSchema: searchOutputSchema,
 			},
 			{
 				Name:        "search_with_regex",
@@ -527,7 +1180,7 @@ func (s *MCPEverythingServer) handleListTools(
 					Properties: map[string]interface{}{
 						"regex": map[string]interface{}{
 							"type":        "string",
-							"description": "正则表达式模式，例如: .*\\.log$",
+							"description": "正则表达式模式（匹配文件名，可包含空格和引号），例如: .*\\.log$",
 						},
 						"path": map[string]interface{}{
 							"type":        "string",
@@ -538,8 +1191,28 @@ func (s *MCPEverythingServer) handleListTools(
 							"description": "最大返回结果数量，默认 100",
 							"default":     100,
 						},
+						"offset": map[string]interface{}{
+							"type":        "integer",
+							"description": "跳过前 N 个结果，用于分页，默认 0",
+							"default":     0,
+						},
+						"cursor": map[string]interface{}{
+							"type":        "string",
+							"description": "上一页返回的 next_cursor，用于获取下一页（优先于 offset）",
+						},
+						"sort": map[string]interface{}{
+							"type":        "string",
+							"description": "排序字段: name, path, size, date_modified，默认按名称",
+							"enum":        []string{"name", "path", "size", "date_modified"},
+						},
+						"order": map[string]interface{}{
+							"type":        "string",
+							"description": "排序方向: asc 或 desc；未指定时 size 和 date_modified 降序，name 和 path 升序",
+							"enum":        []string{"asc", "desc"},
+						},
 					},
 				},
+				OutputSchema: searchOutputSchema,
 			},
 			{
 				Name:        "search_duplicate_names",
@@ -556,8 +1229,28 @@ func (s *MCPEverythingServer) handleListTools(
 							"description": "最大返回结果数量，默认 100",
 							"default":     100,
 						},
+						"offset": map[string]interface{}{
+							"type":        "integer",
+							"description": "跳过前 N 个结果，用于分页，默认 0",
+							"default":     0,
+						},
+						"cursor": map[string]interface{}{
+							"type":        "string",
+							"description": "上一页返回的 next_cursor，用于获取下一页（优先于 offset）",
+						},
+						"sort": map[string]interface{}{
+							"type":        "string",
+							"description": "排序字段: name, path, size, date_modified，默认按名称",
+							"enum":        []string{"name", "path", "size", "date_modified"},
+						},
+						"order": map[string]interface{}{
+							"type":        "string",
+							"description": "排序方向: asc 或 desc；未指定时 size 和 date_modified 降序，name 和 path 升序",
+							"enum":        []string{"asc", "desc"},
+						},
 					},
 				},
+				OutputSchema: searchOutputSchema,
 			},
 			{
 				Name:        "list_drives",
@@ -566,6 +1259,7 @@ func (s *MCPEverythingServer) handleListTools(
 					Type:       "object",
 					Properties: map[string]interface{}{},
 				},
+				OutputSchema: drivesOutputSchema,
 			},
 			{
 				Name:        "list_directory",
@@ -577,6 +1271,11 @@ func (s *MCPEverythingServer) handleListTools(
 							"type":        "string",
 							"description": "要浏览的目录路径，例如: C:\\, C:\\Users, D:\\Projects",
 						},
+						"depth": map[string]interface{}{
+							"type":        "integer",
+							"description": "递归浏览的深度，默认 1（只列出直接子项），最大 10",
+							"default":     1,
+						},
 						"max_results": map[string]interface{}{
 							"type":        "integer",
 							"description": "最大返回结果数量，默认 100",
@@ -584,6 +1283,7 @@ func (s *MCPEverythingServer) handleListTools(
 						},
 					},
 				},
+				OutputSchema: directoryOutputSchema,
 			},
 			{
 				Name:        "get_file_info",
@@ -597,9 +1297,26 @@ func (s *MCPEverythingServer) handleListTools(
 						},
 					},
 				},
+				OutputSchema: fileEntrySchema,
 			},
 		},
-	}, nil
+	}
+
+	// 只有启用缓存时才提供 cache_stats
+	if s.cache != nil {
+		result.Tools = append(result.Tools, Tool{
+			Name:        "cache_stats",
+			Description: "查看搜索结果缓存的命中统计（命中、未命中、合并的请求、淘汰次数和内存占用）。",
+			InputSchema: mcp.ToolInputSchema{
+				Type:       "object",
+				Properties: map[string]interface{}{},
+			},
+			OutputSchema: cacheStatsSchema,
+		})
+	}
+
+	result.Tools = s.enabledTools(result.Tools)
+	return result, nil
 }
 
 // handleCallTool 处理工具调用请求
@@ -607,7 +1324,19 @@ func (s *MCPEverythingServer) handleCallTool(
 	ctx context.Context,
 	name string,
 	args map[string]interface{},
-) (*mcp.CallToolResult, error) {
+) (*CallToolResult, error) {
+	if isKnownTool(name) && !s.tools[name] {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("工具 %s 已被禁用", name),
+				},
+			},
+		}, nil
+	}
+
 	switch name {
 	case "search_files":
 		return s.handleSearchFiles(ctx, args)
@@ -637,8 +1366,13 @@ func (s *MCPEverythingServer) handleCallTool(
 		return s.handleListDirectory(ctx, args)
 	case "get_file_info":
 		return s.handleGetFileInfo(ctx, args)
+	case "cache_stats":
+		if s.cache != nil {
+			return s.handleCacheStats(ctx, args)
+		}
+		fallthrough
 	default:
-		return &mcp.CallToolResult{
+		return &CallToolResult{
 			IsError: true,
 			Content: []mcp.Content{
 				mcp.TextContent{
@@ -654,28 +1388,66 @@ func (s *MCPEverythingServer) handleCallTool(
 func (s *MCPEverythingServer) handleSearchFiles(
 	ctx context.Context,
 	args map[string]interface{},
-) (*mcp.CallToolResult, error) {
+) (*CallToolResult, error) {
 	query, ok := args["query"].(string)
 	if !ok || query == "" {
-		return &mcp.CallToolResult{
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: "query 参数是必需的且必须是非空字符串",
+				},
+			},
+		}, nil
+	}
+
+	maxResults := s.maxResults("search_files", args)
+
+	searchQuery, err := NewQuery(Raw(query)).Build()
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
+	}
+
+	sortOrder, err := parseSort(args, SearchSort{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
+	}
+
+	opts := SearchOptions{Query: searchQuery, MaxResults: maxResults, Sort: sortOrder}
+	applyMatchOptions(args, &opts)
+	opts.Offset, err = pageOffset(args, opts)
+	if err != nil {
+		return &CallToolResult{
 			IsError: true,
 			Content: []mcp.Content{
 				mcp.TextContent{
 					Type: "text",
-					Text: "query 参数是必需的且必须是非空字符串",
+					Text: err.Error(),
 				},
 			},
 		}, nil
 	}
 
-	maxResults := 100
-	if mr, ok := args["max_results"].(float64); ok {
-		maxResults = int(mr)
-	}
-
-	results, err := s.client.Search(ctx, query, maxResults)
+	response, err := s.client.Search(ctx, opts)
 	if err != nil {
-		return &mcp.CallToolResult{
+		return &CallToolResult{
 			IsError: true,
 			Content: []mcp.Content{
 				mcp.TextContent{
@@ -685,6 +1457,8 @@ func (s *MCPEverythingServer) handleSearchFiles(
 			},
 		}, nil
 	}
+	results := response.Results
+	cursor := nextCursor(opts, len(results)+response.Redacted, response.TotalResults)
 
 	// 格式化结果
 	resultText := fmt.Sprintf("搜索查询: %s\n找到 %d 个结果:\n\n", query, len(results))
@@ -693,7 +1467,7 @@ func (s *MCPEverythingServer) handleSearchFiles(
 			break
 		}
 		// 基本信息
-		resultText += fmt.Sprintf("%d. %s\n", i+1, result.Path)
+		resultText += fmt.Sprintf("%d. %s\n", opts.Offset+i+1, displayPath(result))
 		
 		// 添加类型信息
 		if result.Type != "" {
@@ -710,20 +1484,24 @@ func (s *MCPEverythingServer) handleSearchFiles(
 		}
 		
 		// 添加日期信息（如果有）
-		if result.Date != "" {
-			resultText += fmt.Sprintf("   修改时间: %s\n", result.Date)
+		if !result.DateModified.IsZero() {
+			resultText += fmt.Sprintf("   修改时间: %s\n", s.displayTime(result.DateModified))
 		}
 		
 		resultText += "\n"
 	}
 
-	return &mcp.CallToolResult{
+	resultText += formatPageInfo(opts.Offset, len(results)+response.Redacted, response.TotalResults, cursor)
+	resultText += formatWarnings(response.Warnings)
+
+	return &CallToolResult{
 		Content: []mcp.Content{
 			mcp.TextContent{
 				Type: "text",
 				Text: resultText,
 			},
 		},
+		StructuredContent: newSearchOutput(opts, response),
 	}, nil
 }
 
@@ -731,10 +1509,10 @@ func (s *MCPEverythingServer) handleSearchFiles(
 func (s *MCPEverythingServer) handleSearchByExtension(
 	ctx context.Context,
 	args map[string]interface{},
-) (*mcp.CallToolResult, error) {
+) (*CallToolResult, error) {
 	extension, ok := args["extension"].(string)
 	if !ok || extension == "" {
-		return &mcp.CallToolResult{
+		return &CallToolResult{
 			IsError: true,
 			Content: []mcp.Content{
 				mcp.TextContent{
@@ -745,19 +1523,52 @@ func (s *MCPEverythingServer) handleSearchByExtension(
 		}, nil
 	}
 
-	// 移除可能的点号
-	extension = strings.TrimPrefix(extension, ".")
+	maxResults := s.maxResults("search_by_extension", args)
+
+	// Everything 支持 ext: 语法
+	query, err := NewQuery(Ext(extension)).Build()
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
+	}
 
-	maxResults := 100
-	if mr, ok := args["max_results"].(float64); ok {
-		maxResults = int(mr)
+	sortOrder, err := parseSort(args, SearchSort{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
 	}
 
-	// Everything 支持 ext: 语法
-	query := fmt.Sprintf("ext:%s", extension)
-	results, err := s.client.Search(ctx, query, maxResults)
+	opts := SearchOptions{Query: query, MaxResults: maxResults, Sort: sortOrder}
+	opts.Offset, err = pageOffset(args, opts)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
+	}
+
+	response, err := s.client.Search(ctx, opts)
 	if err != nil {
-		return &mcp.CallToolResult{
+		return &CallToolResult{
 			IsError: true,
 			Content: []mcp.Content{
 				mcp.TextContent{
@@ -767,14 +1578,16 @@ func (s *MCPEverythingServer) handleSearchByExtension(
 			},
 		}, nil
 	}
+	results := response.Results
+	cursor := nextCursor(opts, len(results)+response.Redacted, response.TotalResults)
 
 	// 格式化结果
-	resultText := fmt.Sprintf("扩展名搜索: .%s\n找到 %d 个结果:\n\n", extension, len(results))
+	resultText := fmt.Sprintf("扩展名搜索: %s\n找到 %d 个结果:\n\n", query, len(results))
 	for i, result := range results {
 		if i >= maxResults {
 			break
 		}
-		resultText += fmt.Sprintf("%d. %s\n", i+1, result.Path)
+		resultText += fmt.Sprintf("%d. %s\n", opts.Offset+i+1, displayPath(result))
 		if result.Type == "folder" {
 			resultText += "   大小: -\n"
 		} else if result.Size > 0 {
@@ -782,19 +1595,23 @@ func (s *MCPEverythingServer) handleSearchByExtension(
 		} else if result.Type == "file" {
 			resultText += "   大小: 0 B\n"
 		}
-		if result.Date != "" {
-			resultText += fmt.Sprintf("   修改时间: %s\n", result.Date)
+		if !result.DateModified.IsZero() {
+			resultText += fmt.Sprintf("   修改时间: %s\n", s.displayTime(result.DateModified))
 		}
 		resultText += "\n"
 	}
 
-	return &mcp.CallToolResult{
+	resultText += formatPageInfo(opts.Offset, len(results)+response.Redacted, response.TotalResults, cursor)
+	resultText += formatWarnings(response.Warnings)
+
+	return &CallToolResult{
 		Content: []mcp.Content{
 			mcp.TextContent{
 				Type: "text",
 				Text: resultText,
 			},
 		},
+		StructuredContent: newSearchOutput(opts, response),
 	}, nil
 }
 
@@ -802,10 +1619,10 @@ func (s *MCPEverythingServer) handleSearchByExtension(
 func (s *MCPEverythingServer) handleSearchByPath(
 	ctx context.Context,
 	args map[string]interface{},
-) (*mcp.CallToolResult, error) {
+) (*CallToolResult, error) {
 	path, ok := args["path"].(string)
 	if !ok || path == "" {
-		return &mcp.CallToolResult{
+		return &CallToolResult{
 			IsError: true,
 			Content: []mcp.Content{
 				mcp.TextContent{
@@ -816,20 +1633,54 @@ func (s *MCPEverythingServer) handleSearchByPath(
 		}, nil
 	}
 
-	maxResults := 100
-	if mr, ok := args["max_results"].(float64); ok {
-		maxResults = int(mr)
-	}
+	maxResults := s.maxResults("search_by_path", args)
 
 	// 构建查询：路径 + 可选的关键词
-	query := path
-	if q, ok := args["query"].(string); ok && q != "" {
-		query = fmt.Sprintf("%s %s", path, q)
+	q, _ := args["query"].(string)
+	query, err := NewQuery(Text(path), Raw(q)).Build()
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
+	}
+
+	sortOrder, err := parseSort(args, SearchSort{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
+	}
+
+	opts := SearchOptions{Query: query, MaxResults: maxResults, Sort: sortOrder}
+	applyMatchOptions(args, &opts)
+	opts.Offset, err = pageOffset(args, opts)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
 	}
 
-	results, err := s.client.Search(ctx, query, maxResults)
+	response, err := s.client.Search(ctx, opts)
 	if err != nil {
-		return &mcp.CallToolResult{
+		return &CallToolResult{
 			IsError: true,
 			Content: []mcp.Content{
 				mcp.TextContent{
@@ -839,6 +1690,8 @@ func (s *MCPEverythingServer) handleSearchByPath(
 			},
 		}, nil
 	}
+	results := response.Results
+	cursor := nextCursor(opts, len(results)+response.Redacted, response.TotalResults)
 
 	// 格式化结果
 	resultText := fmt.Sprintf("路径搜索: %s\n找到 %d 个结果:\n\n", path, len(results))
@@ -846,7 +1699,7 @@ func (s *MCPEverythingServer) handleSearchByPath(
 		if i >= maxResults {
 			break
 		}
-		resultText += fmt.Sprintf("%d. %s\n", i+1, result.Path)
+		resultText += fmt.Sprintf("%d. %s\n", opts.Offset+i+1, displayPath(result))
 		if result.Type != "" {
 			resultText += fmt.Sprintf("   类型: %s\n", result.Type)
 		}
@@ -857,43 +1710,106 @@ func (s *MCPEverythingServer) handleSearchByPath(
 		} else if result.Type == "file" {
 			resultText += "   大小: 0 B\n"
 		}
-		if result.Date != "" {
-			resultText += fmt.Sprintf("   修改时间: %s\n", result.Date)
+		if !result.DateModified.IsZero() {
+			resultText += fmt.Sprintf("   修改时间: %s\n", s.displayTime(result.DateModified))
 		}
 		resultText += "\n"
 	}
 
-	return &mcp.CallToolResult{
+	resultText += formatPageInfo(opts.Offset, len(results)+response.Redacted, response.TotalResults, cursor)
+	resultText += formatWarnings(response.Warnings)
+
+	return &CallToolResult{
 		Content: []mcp.Content{
 			mcp.TextContent{
 				Type: "text",
 				Text: resultText,
 			},
 		},
+		StructuredContent: newSearchOutput(opts, response),
 	}, nil
 }
 
-// Serve 启动 MCP 服务器
-func (s *MCPEverythingServer) Serve() error {
-	return serveStdioWithNotificationSupport(s.server)
+// stdioWriter 串行化对 stdout 的写入，保证并发完成的响应不会交错
+type stdioWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// writeMessage 写入一条以换行结尾的 JSON-RPC 消息
+func (sw *stdioWriter) writeMessage(msg []byte) error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if _, err := sw.w.Write(msg); err != nil {
+		return err
+	}
+	_, err := sw.w.Write([]byte("\n"))
+	return err
 }
 
 // serveStdioWithNotificationSupport 自定义 stdio 服务器，正确处理通知
-func serveStdioWithNotificationSupport(mcpServer *server.DefaultServer) error {
+// 每个请求在独立的 goroutine 中处理，响应按完成顺序写出（由 id 匹配），
+// 同时处理的请求数不超过 maxInFlight；clients 不为 nil 时，广播的通知也会写给客户端
+func serveStdioWithNotificationSupport(mcpServer requestHandler, maxInFlight int, clients *broadcaster) error {
 	// 复制 mcp-go 的 ServeStdio 实现，但添加通知支持
 	reader := bufio.NewReader(os.Stdin)
+	writer := &stdioWriter{w: os.Stdout}
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	if maxInFlight <= 0 {
+		maxInFlight = defaultMaxInFlight
+	}
+	slots := make(chan struct{}, maxInFlight)
+	var inFlight sync.WaitGroup
+	// queued 在最后一个排队的请求拿到名额后关闭
+	queued := make(chan struct{})
+	close(queued)
+	session := &clientSession{
+		requests: newInflightRequests(),
+		notify: func(msg []byte) {
+			if err := writer.writeMessage(msg); err != nil {
+				log.Printf("写入通知失败: %v\n", err)
+			}
+		},
+	}
+	session.subscriber = newSubscriber(session.notify)
+	defer session.subscriber.close()
+	defer clients.subscribe(session.notify)()
+
 	// 处理信号
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(sigChan)
 
 	done := make(chan struct{})
 	go func() {
-		<-sigChan
-		close(done)
-		cancel()
+		select {
+		case <-sigChan:
+			close(done)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	// 单独的 goroutine 负责按行读取 stdin
+	readChan := make(chan string)
+	errChan := make(chan error, 1)
+	go func() {
+		for {
+			line, err := reader.ReadString('\n')
+			if line != "" {
+				select {
+				case readChan <- line:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err != nil {
+				errChan <- err
+				return
+			}
+		}
 	}()
 
 	for {
@@ -902,149 +1818,123 @@ func serveStdioWithNotificationSupport(mcpServer *server.DefaultServer) error {
 			return nil
 		case <-done:
 			return nil
-		default:
-			// 读取一行
-			readChan := make(chan string, 1)
-			errChan := make(chan error, 1)
+		case err := <-errChan:
+			// 输入结束后等待已接收的请求处理完毕，确保响应全部写出
+			inFlight.Wait()
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		case line := <-readChan:
+			// 通知（例如 notifications/cancelled）立即处理，不占用并发名额
+			if isNotificationMessage(line) {
+				handleMessageWithNotifications(ctx, mcpServer, session, line)
+				continue
+			}
 
+			// 达到并发上限时请求在各自的 goroutine 中排队等待名额，读取循环不会阻塞，
+			// 之后到达的 notifications/cancelled 仍能取消正在处理的请求
+			// 每个请求等前一个请求拿到名额后再排队，保证按到达顺序开始处理
+			prev, acquired := queued, make(chan struct{})
+			queued = acquired
+			inFlight.Add(1)
 			go func() {
-				line, err := reader.ReadString('\n')
-				if err != nil {
-					errChan <- err
+				defer inFlight.Done()
+				select {
+				case <-prev:
+				case <-done:
 					return
 				}
-				readChan <- line
-			}()
-
-			select {
-			case <-ctx.Done():
-				return nil
-			case err := <-errChan:
-				if err == io.EOF {
-					return nil
+				select {
+				case slots <- struct{}{}:
+				case <-done:
+					return
 				}
-				return err
-			case line := <-readChan:
-				if err := handleMessageWithNotifications(ctx, mcpServer, line); err != nil {
-					if err == io.EOF {
-						return nil
-					}
-					// 对于通知错误，继续处理
-					if strings.Contains(err.Error(), "notifications/initialized") {
-						continue
+				close(acquired)
+				defer func() { <-slots }()
+
+				response, err := handleMessageWithNotifications(ctx, mcpServer, session, line)
+				if response != nil {
+					if werr := writer.writeMessage(response); werr != nil {
+						log.Printf("写入响应失败: %v\n", werr)
 					}
 				}
-			}
-		}
-	}
-}
-
-// handleMessageWithNotifications 处理消息，正确识别通知
-func handleMessageWithNotifications(ctx context.Context, mcpServer *server.DefaultServer, line string) error {
-	// 解析 JSON-RPC 消息
-	var msg map[string]interface{}
-	if err := json.Unmarshal([]byte(line), &msg); err != nil {
-		return fmt.Errorf("failed to parse JSON: %w", err)
-	}
-
-	// 检查是否是通知（没有 id 字段或 id 为 null）
-	_, hasID := msg["id"]
-	idValue, idExists := msg["id"]
-	isNotification := !hasID || (idExists && idValue == nil)
-
-	method, ok := msg["method"].(string)
-	if !ok {
-		return fmt.Errorf("missing method field")
-	}
-
-	// 如果是通知，静默处理，不发送响应
-	if isNotification {
-		// 对于 notifications/initialized，直接忽略
-		if method == "notifications/initialized" {
-			return nil
-		}
-		// 其他通知也忽略
-		return nil
-	}
-
-	// 对于请求，使用正常的处理流程
-	// 将消息转换为 JSON-RPC 请求格式
-	params, _ := msg["params"].(map[string]interface{})
-	paramsBytes, _ := json.Marshal(params)
-	if paramsBytes == nil {
-		paramsBytes = []byte("{}")
-	}
-
-	// 调用服务器处理请求
-	result, err := mcpServer.Request(ctx, method, json.RawMessage(paramsBytes))
-	if err != nil {
-		// 发送错误响应
-		response := map[string]interface{}{
-			"jsonrpc": "2.0",
-			"id":      msg["id"],
-			"error": map[string]interface{}{
-				"code":    -32603,
-				"message": err.Error(),
-			},
+				if err != nil && os.Getenv("EVERYTHING_DEBUG") == "true" {
+					fmt.Fprintf(os.Stderr, "[DEBUG] 处理消息失败: %v\n", err)
+				}
+			}()
 		}
-		responseBytes, _ := json.Marshal(response)
-		fmt.Println(string(responseBytes))
-		return err
-	}
-
-	// 发送成功响应
-	response := map[string]interface{}{
-		"jsonrpc": "2.0",
-		"id":      msg["id"],
-		"result":  result,
 	}
-	responseBytes, _ := json.Marshal(response)
-	fmt.Println(string(responseBytes))
-
-	return nil
 }
 
 // handleSearchBySize 处理按大小搜索请求
 func (s *MCPEverythingServer) handleSearchBySize(
 	ctx context.Context,
 	args map[string]interface{},
-) (*mcp.CallToolResult, error) {
+) (*CallToolResult, error) {
 	sizeMin, _ := args["size_min"].(string)
 	sizeMax, _ := args["size_max"].(string)
 	query, _ := args["query"].(string)
 
-	maxResults := 100
-	if mr, ok := args["max_results"].(float64); ok {
-		maxResults = int(mr)
+	maxResults := s.maxResults("search_by_size", args)
+
+	if sizeMin == "" && sizeMax == "" {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: "至少需要提供 size_min 或 size_max 参数",
+				},
+			},
+		}, nil
 	}
 
 	// 构建 Everything 搜索语法
-	searchQuery := ""
-	if sizeMin != "" {
-		searchQuery += fmt.Sprintf("size:>%s ", sizeMin)
-	}
-	if sizeMax != "" {
-		searchQuery += fmt.Sprintf("size:<%s ", sizeMax)
+	searchQuery, err := NewQuery(SizeGT(sizeMin), SizeLT(sizeMax), Raw(query)).Build()
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
 	}
-	if query != "" {
-		searchQuery += query
+
+	// 默认最大的文件优先
+	sortOrder, err := parseSort(args, SearchSort{Field: "size"})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
 	}
 
-	if searchQuery == "" {
-		return &mcp.CallToolResult{
+	opts := SearchOptions{Query: searchQuery, MaxResults: maxResults, Sort: sortOrder}
+	opts.Offset, err = pageOffset(args, opts)
+	if err != nil {
+		return &CallToolResult{
 			IsError: true,
 			Content: []mcp.Content{
 				mcp.TextContent{
 					Type: "text",
-					Text: "至少需要提供 size_min 或 size_max 参数",
+					Text: err.Error(),
 				},
 			},
 		}, nil
 	}
 
-	results, err := s.client.Search(ctx, strings.TrimSpace(searchQuery), maxResults)
+	response, err := s.client.Search(ctx, opts)
 	if err != nil {
-		return &mcp.CallToolResult{
+		return &CallToolResult{
 			IsError: true,
 			Content: []mcp.Content{
 				mcp.TextContent{
@@ -1054,29 +1944,35 @@ func (s *MCPEverythingServer) handleSearchBySize(
 			},
 		}, nil
 	}
+	results := response.Results
+	cursor := nextCursor(opts, len(results)+response.Redacted, response.TotalResults)
 
 	resultText := fmt.Sprintf("大小搜索: %s\n找到 %d 个结果:\n\n", searchQuery, len(results))
 	for i, result := range results {
 		if i >= maxResults {
 			break
 		}
-		resultText += fmt.Sprintf("%d. %s\n", i+1, result.Path)
+		resultText += fmt.Sprintf("%d. %s\n", opts.Offset+i+1, displayPath(result))
 		if result.Size > 0 {
 			resultText += fmt.Sprintf("   大小: %s\n", formatFileSize(result.Size))
 		}
-		if result.Date != "" {
-			resultText += fmt.Sprintf("   修改时间: %s\n", result.Date)
+		if !result.DateModified.IsZero() {
+			resultText += fmt.Sprintf("   修改时间: %s\n", s.displayTime(result.DateModified))
 		}
 		resultText += "\n"
 	}
 
-	return &mcp.CallToolResult{
+	resultText += formatPageInfo(opts.Offset, len(results)+response.Redacted, response.TotalResults, cursor)
+	resultText += formatWarnings(response.Warnings)
+
+	return &CallToolResult{
 		Content: []mcp.Content{
 			mcp.TextContent{
 				Type: "text",
 				Text: resultText,
 			},
 		},
+		StructuredContent: newSearchOutput(opts, response),
 	}, nil
 }
 
@@ -1084,7 +1980,7 @@ func (s *MCPEverythingServer) handleSearchBySize(
 func (s *MCPEverythingServer) handleSearchByDate(
 	ctx context.Context,
 	args map[string]interface{},
-) (*mcp.CallToolResult, error) {
+) (*CallToolResult, error) {
 	dateType, _ := args["date_type"].(string)
 	if dateType == "" {
 		dateType = "modified"
@@ -1093,45 +1989,65 @@ func (s *MCPEverythingServer) handleSearchByDate(
 	dateTo, _ := args["date_to"].(string)
 	query, _ := args["query"].(string)
 
-	maxResults := 100
-	if mr, ok := args["max_results"].(float64); ok {
-		maxResults = int(mr)
-	}
+	maxResults := s.maxResults("search_by_date", args)
 
-	// 构建 Everything 搜索语法
-	searchQuery := ""
-	prefix := "dm:"
-	if dateType == "created" {
-		prefix = "dc:"
+	if dateFrom == "" && dateTo == "" {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: "至少需要提供 date_from 或 date_to 参数",
+				},
+			},
+		}, nil
 	}
 
-	if dateFrom != "" && dateTo != "" {
-		searchQuery = fmt.Sprintf("%s%s..%s", prefix, dateFrom, dateTo)
-	} else if dateFrom != "" {
-		searchQuery = fmt.Sprintf("%s>%s", prefix, dateFrom)
-	} else if dateTo != "" {
-		searchQuery = fmt.Sprintf("%s<%s", prefix, dateTo)
+	// 构建 Everything 搜索语法
+	searchQuery, err := NewQuery(DateRange(dateType, dateFrom, dateTo), Raw(query)).Build()
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
 	}
 
-	if searchQuery == "" {
-		return &mcp.CallToolResult{
+	// 默认最新的文件优先
+	sortOrder, err := parseSort(args, SearchSort{Field: "date_modified"})
+	if err != nil {
+		return &CallToolResult{
 			IsError: true,
 			Content: []mcp.Content{
 				mcp.TextContent{
 					Type: "text",
-					Text: "至少需要提供 date_from 或 date_to 参数",
+					Text: err.Error(),
 				},
 			},
 		}, nil
 	}
 
-	if query != "" {
-		searchQuery += " " + query
+	opts := SearchOptions{Query: searchQuery, MaxResults: maxResults, Sort: sortOrder}
+	opts.Offset, err = pageOffset(args, opts)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
 	}
 
-	results, err := s.client.Search(ctx, searchQuery, maxResults)
+	response, err := s.client.Search(ctx, opts)
 	if err != nil {
-		return &mcp.CallToolResult{
+		return &CallToolResult{
 			IsError: true,
 			Content: []mcp.Content{
 				mcp.TextContent{
@@ -1141,29 +2057,35 @@ func (s *MCPEverythingServer) handleSearchByDate(
 			},
 		}, nil
 	}
+	results := response.Results
+	cursor := nextCursor(opts, len(results)+response.Redacted, response.TotalResults)
 
 	resultText := fmt.Sprintf("日期搜索 (%s): %s\n找到 %d 个结果:\n\n", dateType, searchQuery, len(results))
 	for i, result := range results {
 		if i >= maxResults {
 			break
 		}
-		resultText += fmt.Sprintf("%d. %s\n", i+1, result.Path)
+		resultText += fmt.Sprintf("%d. %s\n", opts.Offset+i+1, displayPath(result))
 		if result.Size > 0 {
 			resultText += fmt.Sprintf("   大小: %s\n", formatFileSize(result.Size))
 		}
-		if result.Date != "" {
-			resultText += fmt.Sprintf("   修改时间: %s\n", result.Date)
+		if !result.DateModified.IsZero() {
+			resultText += fmt.Sprintf("   修改时间: %s\n", s.displayTime(result.DateModified))
 		}
 		resultText += "\n"
 	}
 
-	return &mcp.CallToolResult{
+	resultText += formatPageInfo(opts.Offset, len(results)+response.Redacted, response.TotalResults, cursor)
+	resultText += formatWarnings(response.Warnings)
+
+	return &CallToolResult{
 		Content: []mcp.Content{
 			mcp.TextContent{
 				Type: "text",
 				Text: resultText,
 			},
 		},
+		StructuredContent: newSearchOutput(opts, response),
 	}, nil
 }
 
@@ -1171,27 +2093,60 @@ func (s *MCPEverythingServer) handleSearchByDate(
 func (s *MCPEverythingServer) handleSearchRecentFiles(
 	ctx context.Context,
 	args map[string]interface{},
-) (*mcp.CallToolResult, error) {
+) (*CallToolResult, error) {
 	days := 7
 	if d, ok := args["days"].(float64); ok {
 		days = int(d)
 	}
 	query, _ := args["query"].(string)
 
-	maxResults := 100
-	if mr, ok := args["max_results"].(float64); ok {
-		maxResults = int(mr)
-	}
+	maxResults := s.maxResults("search_recent_files", args)
 
 	// 构建 Everything 搜索语法：最近N天修改的文件
-	searchQuery := fmt.Sprintf("dm:last%ddays", days)
-	if query != "" {
-		searchQuery += " " + query
+	searchQuery, err := NewQuery(ModifiedWithinDays(days), Raw(query)).Build()
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
+	}
+
+	// 默认最新的文件优先
+	sortOrder, err := parseSort(args, SearchSort{Field: "date_modified"})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
+	}
+
+	opts := SearchOptions{Query: searchQuery, MaxResults: maxResults, Sort: sortOrder}
+	opts.Offset, err = pageOffset(args, opts)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
 	}
 
-	results, err := s.client.Search(ctx, searchQuery, maxResults)
+	response, err := s.client.Search(ctx, opts)
 	if err != nil {
-		return &mcp.CallToolResult{
+		return &CallToolResult{
 			IsError: true,
 			Content: []mcp.Content{
 				mcp.TextContent{
@@ -1201,29 +2156,35 @@ func (s *MCPEverythingServer) handleSearchRecentFiles(
 			},
 		}, nil
 	}
+	results := response.Results
+	cursor := nextCursor(opts, len(results)+response.Redacted, response.TotalResults)
 
 	resultText := fmt.Sprintf("最近 %d 天修改的文件\n找到 %d 个结果:\n\n", days, len(results))
 	for i, result := range results {
 		if i >= maxResults {
 			break
 		}
-		resultText += fmt.Sprintf("%d. %s\n", i+1, result.Path)
+		resultText += fmt.Sprintf("%d. %s\n", opts.Offset+i+1, displayPath(result))
 		if result.Size > 0 {
 			resultText += fmt.Sprintf("   大小: %s\n", formatFileSize(result.Size))
 		}
-		if result.Date != "" {
-			resultText += fmt.Sprintf("   修改时间: %s\n", result.Date)
+		if !result.DateModified.IsZero() {
+			resultText += fmt.Sprintf("   修改时间: %s\n", s.displayTime(result.DateModified))
 		}
 		resultText += "\n"
 	}
 
-	return &mcp.CallToolResult{
+	resultText += formatPageInfo(opts.Offset, len(results)+response.Redacted, response.TotalResults, cursor)
+	resultText += formatWarnings(response.Warnings)
+
+	return &CallToolResult{
 		Content: []mcp.Content{
 			mcp.TextContent{
 				Type: "text",
 				Text: resultText,
 			},
 		},
+		StructuredContent: newSearchOutput(opts, response),
 	}, nil
 }
 
@@ -1231,27 +2192,60 @@ func (s *MCPEverythingServer) handleSearchRecentFiles(
 func (s *MCPEverythingServer) handleSearchLargeFiles(
 	ctx context.Context,
 	args map[string]interface{},
-) (*mcp.CallToolResult, error) {
+) (*CallToolResult, error) {
 	minSize, _ := args["min_size"].(string)
 	if minSize == "" {
 		minSize = "100MB"
 	}
 	path, _ := args["path"].(string)
 
-	maxResults := 100
-	if mr, ok := args["max_results"].(float64); ok {
-		maxResults = int(mr)
-	}
+	maxResults := s.maxResults("search_large_files", args)
 
 	// 构建 Everything 搜索语法
-	searchQuery := fmt.Sprintf("size:>%s", minSize)
-	if path != "" {
-		searchQuery += fmt.Sprintf(" path:\"%s\"", path)
+	searchQuery, err := NewQuery(SizeGT(minSize), Path(path)).Build()
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
+	}
+
+	// 默认最大的文件优先
+	sortOrder, err := parseSort(args, SearchSort{Field: "size"})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
+	}
+
+	opts := SearchOptions{Query: searchQuery, MaxResults: maxResults, Sort: sortOrder}
+	opts.Offset, err = pageOffset(args, opts)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
 	}
 
-	results, err := s.client.Search(ctx, searchQuery, maxResults)
+	response, err := s.client.Search(ctx, opts)
 	if err != nil {
-		return &mcp.CallToolResult{
+		return &CallToolResult{
 			IsError: true,
 			Content: []mcp.Content{
 				mcp.TextContent{
@@ -1261,29 +2255,35 @@ func (s *MCPEverythingServer) handleSearchLargeFiles(
 			},
 		}, nil
 	}
+	results := response.Results
+	cursor := nextCursor(opts, len(results)+response.Redacted, response.TotalResults)
 
 	resultText := fmt.Sprintf("大文件搜索 (>%s)\n找到 %d 个结果:\n\n", minSize, len(results))
 	for i, result := range results {
 		if i >= maxResults {
 			break
 		}
-		resultText += fmt.Sprintf("%d. %s\n", i+1, result.Path)
+		resultText += fmt.Sprintf("%d. %s\n", opts.Offset+i+1, displayPath(result))
 		if result.Size > 0 {
 			resultText += fmt.Sprintf("   大小: %s\n", formatFileSize(result.Size))
 		}
-		if result.Date != "" {
-			resultText += fmt.Sprintf("   修改时间: %s\n", result.Date)
+		if !result.DateModified.IsZero() {
+			resultText += fmt.Sprintf("   修改时间: %s\n", s.displayTime(result.DateModified))
 		}
 		resultText += "\n"
 	}
 
-	return &mcp.CallToolResult{
+	resultText += formatPageInfo(opts.Offset, len(results)+response.Redacted, response.TotalResults, cursor)
+	resultText += formatWarnings(response.Warnings)
+
+	return &CallToolResult{
 		Content: []mcp.Content{
 			mcp.TextContent{
 				Type: "text",
 				Text: resultText,
 			},
 		},
+		StructuredContent: newSearchOutput(opts, response),
 	}, nil
 }
 
@@ -1291,33 +2291,63 @@ func (s *MCPEverythingServer) handleSearchLargeFiles(
 func (s *MCPEverythingServer) handleSearchEmptyFiles(
 	ctx context.Context,
 	args map[string]interface{},
-) (*mcp.CallToolResult, error) {
+) (*CallToolResult, error) {
 	fileType, _ := args["type"].(string)
 	if fileType == "" {
 		fileType = "file"
 	}
 	path, _ := args["path"].(string)
 
-	maxResults := 100
-	if mr, ok := args["max_results"].(float64); ok {
-		maxResults = int(mr)
+	maxResults := s.maxResults("search_empty_files", args)
+
+	// 构建 Everything 搜索语法
+	query := NewQuery(Files(), SizeEQ("0"))
+	if fileType == "folder" {
+		query = NewQuery(Folders(), Empty())
+	}
+	searchQuery, err := query.And(Path(path)).Build()
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
 	}
 
-	// 构建 Everything 搜索语法
-	searchQuery := ""
-	if fileType == "folder" {
-		searchQuery = "folder: empty:"
-	} else {
-		searchQuery = "file: size:0"
+	sortOrder, err := parseSort(args, SearchSort{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
 	}
 
-	if path != "" {
-		searchQuery += fmt.Sprintf(" path:\"%s\"", path)
+	opts := SearchOptions{Query: searchQuery, MaxResults: maxResults, Sort: sortOrder}
+	opts.Offset, err = pageOffset(args, opts)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
 	}
 
-	results, err := s.client.Search(ctx, searchQuery, maxResults)
+	response, err := s.client.Search(ctx, opts)
 	if err != nil {
-		return &mcp.CallToolResult{
+		return &CallToolResult{
 			IsError: true,
 			Content: []mcp.Content{
 				mcp.TextContent{
@@ -1327,6 +2357,8 @@ func (s *MCPEverythingServer) handleSearchEmptyFiles(
 			},
 		}, nil
 	}
+	results := response.Results
+	cursor := nextCursor(opts, len(results)+response.Redacted, response.TotalResults)
 
 	typeStr := "空文件"
 	if fileType == "folder" {
@@ -1337,34 +2369,48 @@ func (s *MCPEverythingServer) handleSearchEmptyFiles(
 		if i >= maxResults {
 			break
 		}
-		resultText += fmt.Sprintf("%d. %s\n", i+1, result.Path)
+		resultText += fmt.Sprintf("%d. %s\n", opts.Offset+i+1, displayPath(result))
 		if result.Size > 0 {
 			resultText += fmt.Sprintf("   大小: %s\n", formatFileSize(result.Size))
 		}
-		if result.Date != "" {
-			resultText += fmt.Sprintf("   修改时间: %s\n", result.Date)
+		if !result.DateModified.IsZero() {
+			resultText += fmt.Sprintf("   修改时间: %s\n", s.displayTime(result.DateModified))
 		}
 		resultText += "\n"
 	}
 
-	return &mcp.CallToolResult{
+	resultText += formatPageInfo(opts.Offset, len(results)+response.Redacted, response.TotalResults, cursor)
+	resultText += formatWarnings(response.Warnings)
+
+	return &CallToolResult{
 		Content: []mcp.Content{
 			mcp.TextContent{
 				Type: "text",
 				Text: resultText,
 			},
 		},
+		StructuredContent: newSearchOutput(opts, response),
 	}, nil
 }
 
+// contentTypeExtensions 内容类型对应的扩展名
+var contentTypeExtensions = map[string][]string{
+	"image":      {"jpg", "jpeg", "png", "gif", "bmp", "webp", "svg", "ico"},
+	"video":      {"mp4", "avi", "mkv", "mov", "wmv", "flv", "webm", "m4v"},
+	"audio":      {"mp3", "wav", "flac", "aac", "ogg", "wma", "m4a"},
+	"document":   {"doc", "docx", "pdf", "txt", "rtf", "odt", "xls", "xlsx", "ppt", "pptx"},
+	"archive":    {"zip", "rar", "7z", "tar", "gz", "bz2", "xz"},
+	"executable": {"exe", "msi", "bat", "cmd", "sh", "app", "dmg"},
+}
+
 // handleSearchByContentType 处理按内容类型搜索请求
 func (s *MCPEverythingServer) handleSearchByContentType(
 	ctx context.Context,
 	args map[string]interface{},
-) (*mcp.CallToolResult, error) {
+) (*CallToolResult, error) {
 	contentType, ok := args["content_type"].(string)
 	if !ok || contentType == "" {
-		return &mcp.CallToolResult{
+		return &CallToolResult{
 			IsError: true,
 			Content: []mcp.Content{
 				mcp.TextContent{
@@ -1376,42 +2422,64 @@ func (s *MCPEverythingServer) handleSearchByContentType(
 	}
 	query, _ := args["query"].(string)
 
-	maxResults := 100
-	if mr, ok := args["max_results"].(float64); ok {
-		maxResults = int(mr)
+	maxResults := s.maxResults("search_by_content_type", args)
+
+	extensions, exists := contentTypeExtensions[contentType]
+	if !exists {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("不支持的内容类型: %s", contentType),
+				},
+			},
+		}, nil
 	}
 
-	// 定义内容类型对应的扩展名
-	extMap := map[string]string{
-		"image":      "ext:jpg;jpeg;png;gif;bmp;webp;svg;ico",
-		"video":      "ext:mp4;avi;mkv;mov;wmv;flv;webm;m4v",
-		"audio":      "ext:mp3;wav;flac;aac;ogg;wma;m4a",
-		"document":   "ext:doc;docx;pdf;txt;rtf;odt;xls;xlsx;ppt;pptx",
-		"archive":    "ext:zip;rar;7z;tar;gz;bz2;xz",
-		"executable": "ext:exe;msi;bat;cmd;sh;app;dmg",
+	searchQuery, err := NewQuery(Ext(extensions...), Raw(query)).Build()
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
 	}
 
-	extQuery, exists := extMap[contentType]
-	if !exists {
-		return &mcp.CallToolResult{
+	sortOrder, err := parseSort(args, SearchSort{})
+	if err != nil {
+		return &CallToolResult{
 			IsError: true,
 			Content: []mcp.Content{
 				mcp.TextContent{
 					Type: "text",
-					Text: fmt.Sprintf("不支持的内容类型: %s", contentType),
+					Text: err.Error(),
 				},
 			},
 		}, nil
 	}
 
-	searchQuery := extQuery
-	if query != "" {
-		searchQuery += " " + query
+	opts := SearchOptions{Query: searchQuery, MaxResults: maxResults, Sort: sortOrder}
+	opts.Offset, err = pageOffset(args, opts)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
 	}
 
-	results, err := s.client.Search(ctx, searchQuery, maxResults)
+	response, err := s.client.Search(ctx, opts)
 	if err != nil {
-		return &mcp.CallToolResult{
+		return &CallToolResult{
 			IsError: true,
 			Content: []mcp.Content{
 				mcp.TextContent{
@@ -1421,29 +2489,35 @@ func (s *MCPEverythingServer) handleSearchByContentType(
 			},
 		}, nil
 	}
+	results := response.Results
+	cursor := nextCursor(opts, len(results)+response.Redacted, response.TotalResults)
 
 	resultText := fmt.Sprintf("内容类型搜索: %s\n找到 %d 个结果:\n\n", contentType, len(results))
 	for i, result := range results {
 		if i >= maxResults {
 			break
 		}
-		resultText += fmt.Sprintf("%d. %s\n", i+1, result.Path)
+		resultText += fmt.Sprintf("%d. %s\n", opts.Offset+i+1, displayPath(result))
 		if result.Size > 0 {
 			resultText += fmt.Sprintf("   大小: %s\n", formatFileSize(result.Size))
 		}
-		if result.Date != "" {
-			resultText += fmt.Sprintf("   修改时间: %s\n", result.Date)
+		if !result.DateModified.IsZero() {
+			resultText += fmt.Sprintf("   修改时间: %s\n", s.displayTime(result.DateModified))
 		}
 		resultText += "\n"
 	}
 
-	return &mcp.CallToolResult{
+	resultText += formatPageInfo(opts.Offset, len(results)+response.Redacted, response.TotalResults, cursor)
+	resultText += formatWarnings(response.Warnings)
+
+	return &CallToolResult{
 		Content: []mcp.Content{
 			mcp.TextContent{
 				Type: "text",
 				Text: resultText,
 			},
 		},
+		StructuredContent: newSearchOutput(opts, response),
 	}, nil
 }
 
@@ -1451,10 +2525,10 @@ func (s *MCPEverythingServer) handleSearchByContentType(
 func (s *MCPEverythingServer) handleSearchWithRegex(
 	ctx context.Context,
 	args map[string]interface{},
-) (*mcp.CallToolResult, error) {
+) (*CallToolResult, error) {
 	regex, ok := args["regex"].(string)
 	if !ok || regex == "" {
-		return &mcp.CallToolResult{
+		return &CallToolResult{
 			IsError: true,
 			Content: []mcp.Content{
 				mcp.TextContent{
@@ -1466,20 +2540,64 @@ func (s *MCPEverythingServer) handleSearchWithRegex(
 	}
 	path, _ := args["path"].(string)
 
-	maxResults := 100
-	if mr, ok := args["max_results"].(float64); ok {
-		maxResults = int(mr)
+	maxResults := s.maxResults("search_with_regex", args)
+
+	// 先在本地校验正则表达式，避免无效的模式返回令人困惑的空结果
+	if _, err := regexp.Compile(regex); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("无效的正则表达式: %v", err),
+				},
+			},
+		}, nil
 	}
 
-	// 构建 Everything 搜索语法
-	searchQuery := fmt.Sprintf("regex:%s", regex)
+	// 使用 Everything 的 regex=1 参数，整个搜索字符串作为正则表达式
+	// 指定路径时改为匹配完整路径，并把转义后的路径前缀拼接到模式前
+	searchQuery := regex
 	if path != "" {
-		searchQuery += fmt.Sprintf(" path:\"%s\"", path)
+		searchQuery = pathScopedRegex(path, regex)
+	}
+
+	sortOrder, err := parseSort(args, SearchSort{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
+	}
+
+	opts := SearchOptions{
+		Query:      searchQuery,
+		MaxResults: maxResults,
+		Sort:       sortOrder,
+		Regex:      true,
+		MatchPath:  path != "",
+	}
+	opts.Offset, err = pageOffset(args, opts)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
 	}
 
-	results, err := s.client.Search(ctx, searchQuery, maxResults)
+	response, err := s.client.Search(ctx, opts)
 	if err != nil {
-		return &mcp.CallToolResult{
+		return &CallToolResult{
 			IsError: true,
 			Content: []mcp.Content{
 				mcp.TextContent{
@@ -1489,40 +2607,56 @@ func (s *MCPEverythingServer) handleSearchWithRegex(
 			},
 		}, nil
 	}
+	results := response.Results
+	cursor := nextCursor(opts, len(results)+response.Redacted, response.TotalResults)
 
 	resultText := fmt.Sprintf("正则表达式搜索: %s\n找到 %d 个结果:\n\n", regex, len(results))
 	for i, result := range results {
 		if i >= maxResults {
 			break
 		}
-		resultText += fmt.Sprintf("%d. %s\n", i+1, result.Path)
+		resultText += fmt.Sprintf("%d. %s\n", opts.Offset+i+1, displayPath(result))
 		if result.Size > 0 {
 			resultText += fmt.Sprintf("   大小: %s\n", formatFileSize(result.Size))
 		}
-		if result.Date != "" {
-			resultText += fmt.Sprintf("   修改时间: %s\n", result.Date)
+		if !result.DateModified.IsZero() {
+			resultText += fmt.Sprintf("   修改时间: %s\n", s.displayTime(result.DateModified))
 		}
 		resultText += "\n"
 	}
 
-	return &mcp.CallToolResult{
+	resultText += formatPageInfo(opts.Offset, len(results)+response.Redacted, response.TotalResults, cursor)
+	resultText += formatWarnings(response.Warnings)
+
+	return &CallToolResult{
 		Content: []mcp.Content{
 			mcp.TextContent{
 				Type: "text",
 				Text: resultText,
 			},
 		},
+		StructuredContent: newSearchOutput(opts, response),
 	}, nil
 }
 
+// pathScopedRegex 将正则表达式限定在指定路径下（用于匹配完整路径）
+// 以 ^ 开头的模式锚定在文件名开头，其他模式可以匹配文件名中的任意位置
+func pathScopedRegex(path, pattern string) string {
+	prefix := "^" + regexp.QuoteMeta(strings.TrimRight(path, "\\/")) + `[\\/](?:.*[\\/])?`
+	if strings.HasPrefix(pattern, "^") {
+		return prefix + "(?:" + strings.TrimPrefix(pattern, "^") + ")"
+	}
+	return prefix + `[^\\/]*(?:` + pattern + ")"
+}
+
 // handleSearchDuplicateNames 处理重复文件 名搜索请求
 func (s *MCPEverythingServer) handleSearchDuplicateNames(
 	ctx context.Context,
 	args map[string]interface{},
-) (*mcp.CallToolResult, error) {
+) (*CallToolResult, error) {
 	filename, ok := args["filename"].(string)
 	if !ok || filename == "" {
-		return &mcp.CallToolResult{
+		return &CallToolResult{
 			IsError: true,
 			Content: []mcp.Content{
 				mcp.TextContent{
@@ -1533,17 +2667,52 @@ func (s *MCPEverythingServer) handleSearchDuplicateNames(
 		}, nil
 	}
 
-	maxResults := 100
-	if mr, ok := args["max_results"].(float64); ok {
-		maxResults = int(mr)
-	}
+	maxResults := s.maxResults("search_duplicate_names", args)
 
 	// 搜索精确文件名
-	searchQuery := fmt.Sprintf("file:%s", filename)
+	searchQuery, err := NewQuery(FileName(filename)).Build()
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
+	}
+
+	sortOrder, err := parseSort(args, SearchSort{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
+	}
+
+	opts := SearchOptions{Query: searchQuery, MaxResults: maxResults, Sort: sortOrder}
+	opts.Offset, err = pageOffset(args, opts)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
+	}
 
-	results, err := s.client.Search(ctx, searchQuery, maxResults)
+	response, err := s.client.Search(ctx, opts)
 	if err != nil {
-		return &mcp.CallToolResult{
+		return &CallToolResult{
 			IsError: true,
 			Content: []mcp.Content{
 				mcp.TextContent{
@@ -1553,18 +2722,20 @@ func (s *MCPEverythingServer) handleSearchDuplicateNames(
 			},
 		}, nil
 	}
+	results := response.Results
+	cursor := nextCursor(opts, len(results)+response.Redacted, response.TotalResults)
 
 	resultText := fmt.Sprintf("重复文件名搜索: %s\n找到 %d 个结果:\n\n", filename, len(results))
 	for i, result := range results {
 		if i >= maxResults {
 			break
 		}
-		resultText += fmt.Sprintf("%d. %s\n", i+1, result.Path)
+		resultText += fmt.Sprintf("%d. %s\n", opts.Offset+i+1, displayPath(result))
 		if result.Size > 0 {
 			resultText += fmt.Sprintf("   大小: %s\n", formatFileSize(result.Size))
 		}
-		if result.Date != "" {
-			resultText += fmt.Sprintf("   修改时间: %s\n", result.Date)
+		if !result.DateModified.IsZero() {
+			resultText += fmt.Sprintf("   修改时间: %s\n", s.displayTime(result.DateModified))
 		}
 		resultText += "\n"
 	}
@@ -1573,13 +2744,17 @@ func (s *MCPEverythingServer) handleSearchDuplicateNames(
 		resultText += fmt.Sprintf("发现 %d 个同名文件！\n", len(results))
 	}
 
-	return &mcp.CallToolResult{
+	resultText += formatPageInfo(opts.Offset, len(results)+response.Redacted, response.TotalResults, cursor)
+	resultText += formatWarnings(response.Warnings)
+
+	return &CallToolResult{
 		Content: []mcp.Content{
 			mcp.TextContent{
 				Type: "text",
 				Text: resultText,
 			},
 		},
+		StructuredContent: newSearchOutput(opts, response),
 	}, nil
 }
 
@@ -1587,14 +2762,14 @@ func (s *MCPEverythingServer) handleSearchDuplicateNames(
 func (s *MCPEverythingServer) handleListDrives(
 	ctx context.Context,
 	args map[string]interface{},
-) (*mcp.CallToolResult, error) {
+) (*CallToolResult, error) {
 	// 搜索所有根目录（驱动器）
 	// Everything 语法: root: 表示搜索所有驱动器根目录
-	searchQuery := "root:"
+	searchQuery := NewQuery(Roots()).String()
 
-	results, err := s.client.Search(ctx, searchQuery, 100)
+	response, err := s.client.Search(ctx, SearchOptions{Query: searchQuery, MaxResults: 100})
 	if err != nil {
-		return &mcp.CallToolResult{
+		return &CallToolResult{
 			IsError: true,
 			Content: []mcp.Content{
 				mcp.TextContent{
@@ -1604,43 +2779,71 @@ func (s *MCPEverythingServer) handleListDrives(
 			},
 		}, nil
 	}
+	results := response.Results
 
 	// 过滤出驱动器（通常是单个字母后跟冒号）
 	drives := []SearchResult{}
 	for _, result := range results {
-		// 驱动器格式通常是 "C:", "D:" 等
-		if len(result.Path) <= 3 && strings.HasSuffix(result.Path, ":") {
+		if isDrivePath(result.Path) {
 			drives = append(drives, result)
 		}
 	}
 
 	resultText := fmt.Sprintf("系统驱动器列表\n找到 %d 个驱动器:\n\n", len(drives))
 	for i, drive := range drives {
-		resultText += fmt.Sprintf("%d. %s\\\n", i+1, drive.Path)
+		resultText += fmt.Sprintf("%d. %s\n", i+1, withSource(drive, driveRoot(drive.Path)))
 	}
+	resultText += formatWarnings(response.Warnings)
 
 	if len(drives) == 0 {
-		resultText += "提示: 使用 list_directory 工具浏览特定驱动器，例如: C:\\, D:\\\n"
+		if roots := s.policy.Roots(); len(roots) > 0 {
+			resultText += fmt.Sprintf("提示: 访问策略只允许访问以下目录，使用 list_directory 工具浏览: %s\n", strings.Join(roots, ", "))
+		} else {
+			resultText += "提示: 使用 list_directory 工具浏览特定驱动器，例如: C:\\, D:\\\n"
+		}
+	}
+
+	output := DrivesOutput{Count: len(drives), Drives: make([]string, 0, len(drives)), Warnings: response.Warnings, Redacted: response.Redacted}
+	for _, drive := range drives {
+		output.Drives = append(output.Drives, driveRoot(drive.Path))
+		if drive.Source != "" {
+			output.Sources = append(output.Sources, drive.Source)
+		}
 	}
 
-	return &mcp.CallToolResult{
+	return &CallToolResult{
 		Content: []mcp.Content{
 			mcp.TextContent{
 				Type: "text",
 				Text: resultText,
 			},
 		},
+		StructuredContent: output,
 	}, nil
 }
 
+// isDrivePath 判断 root: 查询返回的路径是否为驱动器
+// 驱动器格式通常是 "C:", "D:" 等；本地后端返回配置的根目录（绝对路径）
+func isDrivePath(path string) bool {
+	return len(path) <= 3 && strings.HasSuffix(path, ":") || strings.HasPrefix(path, "/")
+}
+
+// driveRoot 返回驱动器根目录的显示形式：Windows 驱动器加上反斜杠（C: -> C:\\），其他路径保持不变
+func driveRoot(path string) string {
+	if strings.HasSuffix(path, ":") {
+		return path + "\\"
+	}
+	return path
+}
+
 // handleListDirectory 处理列出目录内容请求
 func (s *MCPEverythingServer) handleListDirectory(
 	ctx context.Context,
 	args map[string]interface{},
-) (*mcp.CallToolResult, error) {
+) (*CallToolResult, error) {
 	path, ok := args["path"].(string)
 	if !ok || path == "" {
-		return &mcp.CallToolResult{
+		return &CallToolResult{
 			IsError: true,
 			Content: []mcp.Content{
 				mcp.TextContent{
@@ -1651,24 +2854,61 @@ func (s *MCPEverythingServer) handleListDirectory(
 		}, nil
 	}
 
-	maxResults := 100
-	if mr, ok := args["max_results"].(float64); ok {
-		maxResults = int(mr)
+	maxResults := s.maxResults("list_directory", args)
+
+	depth := 1
+	if d, ok := args["depth"].(float64); ok && d > 1 {
+		depth = int(d)
+		if depth > maxListDirectoryDepth {
+			depth = maxListDirectoryDepth
+		}
 	}
 
-	// 规范化路径
+	// 规范化路径：以路径分隔符结尾（本地后端的 POSIX 路径使用 /）
 	path = strings.TrimSpace(path)
 	if !strings.HasSuffix(path, "\\") && !strings.HasSuffix(path, "/") {
-		path += "\\"
+		if strings.HasPrefix(path, "/") {
+			path += "/"
+		} else {
+			path += "\\"
+		}
+	}
+
+	if !s.policy.Allowed(path) {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("访问策略不允许访问: %s", path),
+				},
+			},
+		}, nil
+	}
+
+	// 深度大于 1 时递归浏览目录树
+	if depth > 1 {
+		return s.listDirectoryTree(ctx, path, depth, maxResults)
 	}
 
 	// 构建搜索查询：查找指定路径下的直接子项
 	// parent: 语法可以查找指定目录的直接子项
-	searchQuery := fmt.Sprintf("parent:\"%s\"", strings.TrimSuffix(path, "\\"))
+	searchQuery, err := NewQuery(Parent(path)).Build()
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
+	}
 
-	results, err := s.client.Search(ctx, searchQuery, maxResults)
+	response, err := s.client.Search(ctx, SearchOptions{Query: searchQuery, MaxResults: maxResults})
 	if err != nil {
-		return &mcp.CallToolResult{
+		return &CallToolResult{
 			IsError: true,
 			Content: []mcp.Content{
 				mcp.TextContent{
@@ -1678,6 +2918,7 @@ func (s *MCPEverythingServer) handleListDirectory(
 			},
 		}, nil
 	}
+	results := response.Results
 
 	// 分类为文件夹和文件
 	folders := []SearchResult{}
@@ -1706,9 +2947,9 @@ func (s *MCPEverythingServer) handleListDirectory(
 			if name == "" {
 				name = folder.Path
 			}
-			resultText += fmt.Sprintf("%d. 📁 %s\n", i+1, name)
-			if folder.Date != "" {
-				resultText += fmt.Sprintf("      修改时间: %s\n", folder.Date)
+			resultText += fmt.Sprintf("%d. 📁 %s\n", i+1, withSource(folder, name))
+			if !folder.DateModified.IsZero() {
+				resultText += fmt.Sprintf("      修改时间: %s\n", s.displayTime(folder.DateModified))
 			}
 		}
 		resultText += "\n"
@@ -1727,12 +2968,12 @@ func (s *MCPEverythingServer) handleListDirectory(
 			if name == "" {
 				name = file.Path
 			}
-			resultText += fmt.Sprintf("%d. 📄 %s\n", i+1, name)
+			resultText += fmt.Sprintf("%d. 📄 %s\n", i+1, withSource(file, name))
 			if file.Size > 0 {
 				resultText += fmt.Sprintf("      大小: %s\n", formatFileSize(file.Size))
 			}
-			if file.Date != "" {
-				resultText += fmt.Sprintf("      修改时间: %s\n", file.Date)
+			if !file.DateModified.IsZero() {
+				resultText += fmt.Sprintf("      修改时间: %s\n", s.displayTime(file.DateModified))
 			}
 			count++
 		}
@@ -1741,14 +2982,157 @@ func (s *MCPEverythingServer) handleListDirectory(
 	if len(folders) == 0 && len(files) == 0 {
 		resultText += "该目录为空或不存在\n"
 	}
+	resultText += formatWarnings(response.Warnings)
+
+	output := DirectoryOutput{
+		Path:     path,
+		Depth:    1,
+		Folders:  len(folders),
+		Files:    len(files),
+		Results:  make([]FileEntry, 0, len(results)),
+		Warnings: response.Warnings,
+		Redacted: response.Redacted,
+	}
+	for _, result := range append(folders, files...) {
+		output.Results = append(output.Results, newFileEntry(result))
+	}
+
+	return &CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: resultText,
+			},
+		},
+		StructuredContent: output,
+	}, nil
+}
+
+// maxListDirectoryDepth list_directory 递归浏览的最大深度
+const maxListDirectoryDepth = 10
+
+// listDirectoryTree 递归浏览目录树（root 以路径分隔符结尾），最多返回 maxResults 个条目
+// 每浏览一个目录都需要一次 Everything 查询，因此每次查询前发送一次进度通知
+func (s *MCPEverythingServer) listDirectoryTree(
+	ctx context.Context,
+	root string,
+	depth int,
+	maxResults int,
+) (*CallToolResult, error) {
+	lines := []string{}
+	entries := []FileEntry{}
+	warnings := []string{}
+	seenWarnings := map[string]bool{}
+	folderCount, fileCount, visited, redacted := 0, 0, 0, 0
+	truncated := false
+
+	var walk func(dir string, level int) error
+	walk = func(dir string, level int) error {
+		if folderCount+fileCount >= maxResults {
+			truncated = true
+			return nil
+		}
+
+		visited++
+		reportProgress(ctx, visited, 0, fmt.Sprintf("浏览目录: %s", dir))
+
+		query, err := NewQuery(Parent(dir)).Build()
+		if err != nil {
+			return err
+		}
+		response, err := s.client.Search(ctx, SearchOptions{
+			Query:      query,
+			MaxResults: maxResults - folderCount - fileCount,
+		})
+		if err != nil {
+			return err
+		}
+		results := response.Results
+		redacted += response.Redacted
+		for _, w := range response.Warnings {
+			if !seenWarnings[w] {
+				seenWarnings[w] = true
+				warnings = append(warnings, w)
+			}
+		}
+
+		// 先列出文件夹，再列出文件
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].Type == "folder" && results[j].Type != "folder"
+		})
+
+		indent := strings.Repeat("   ", level)
+		for _, result := range results {
+			if folderCount+fileCount >= maxResults {
+				truncated = true
+				break
+			}
+			entry := newFileEntry(result)
+			entry.Level = level
+			entries = append(entries, entry)
+			name := withSource(result, entry.Name)
+			if result.Type == "folder" {
+				folderCount++
+				lines = append(lines, fmt.Sprintf("%s📁 %s", indent, name))
+				if level+1 < depth {
+					if err := walk(result.Path, level+1); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			fileCount++
+			if result.Size > 0 {
+				lines = append(lines, fmt.Sprintf("%s📄 %s (%s)", indent, name, formatFileSize(result.Size)))
+			} else {
+				lines = append(lines, fmt.Sprintf("%s📄 %s", indent, name))
+			}
+		}
+		return nil
+	}
+
+	if err := walk(root, 0); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("浏览目录失败: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	resultText := fmt.Sprintf("目录树: %s (深度 %d)\n", root, depth)
+	resultText += fmt.Sprintf("找到 %d 个文件夹, %d 个文件（浏览了 %d 个目录）\n\n", folderCount, fileCount, visited)
+	resultText += strings.Join(lines, "\n")
+	if len(lines) > 0 {
+		resultText += "\n"
+	}
+	if truncated {
+		resultText += fmt.Sprintf("... 已达到 max_results 上限 (%d)，结果被截断\n", maxResults)
+	}
+	if len(lines) == 0 {
+		resultText += "该目录为空或不存在\n"
+	}
+	resultText += formatWarnings(warnings)
 
-	return &mcp.CallToolResult{
+	return &CallToolResult{
 		Content: []mcp.Content{
 			mcp.TextContent{
 				Type: "text",
 				Text: resultText,
 			},
 		},
+		StructuredContent: DirectoryOutput{
+			Path:     root,
+			Depth:    depth,
+			Folders:  folderCount,
+			Files:    fileCount,
+			Results:  entries,
+			Warnings: warnings,
+			Redacted: redacted,
+		},
 	}, nil
 }
 
@@ -1756,10 +3140,10 @@ func (s *MCPEverythingServer) handleListDirectory(
 func (s *MCPEverythingServer) handleGetFileInfo(
 	ctx context.Context,
 	args map[string]interface{},
-) (*mcp.CallToolResult, error) {
+) (*CallToolResult, error) {
 	path, ok := args["path"].(string)
 	if !ok || path == "" {
-		return &mcp.CallToolResult{
+		return &CallToolResult{
 			IsError: true,
 			Content: []mcp.Content{
 				mcp.TextContent{
@@ -1770,12 +3154,22 @@ func (s *MCPEverythingServer) handleGetFileInfo(
 		}, nil
 	}
 
-	// 使用精确路径搜索
-	searchQuery := fmt.Sprintf("\"%s\"", path)
+	if !s.policy.Allowed(path) {
+		return &CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("访问策略不允许访问: %s", path),
+				},
+			},
+		}, nil
+	}
 
-	results, err := s.client.Search(ctx, searchQuery, 1)
+	// 精确查找：文本搜索是子串匹配，第一个结果可能是同名前缀的其他文件（例如 foo.txt.bak）
+	result, warnings, err := s.lookupPath(ctx, path)
 	if err != nil {
-		return &mcp.CallToolResult{
+		return &CallToolResult{
 			IsError: true,
 			Content: []mcp.Content{
 				mcp.TextContent{
@@ -1785,9 +3179,8 @@ func (s *MCPEverythingServer) handleGetFileInfo(
 			},
 		}, nil
 	}
-
-	if len(results) == 0 {
-		return &mcp.CallToolResult{
+	if result == nil {
+		return &CallToolResult{
 			IsError: true,
 			Content: []mcp.Content{
 				mcp.TextContent{
@@ -1798,7 +3191,22 @@ func (s *MCPEverythingServer) handleGetFileInfo(
 		}, nil
 	}
 
-	result := results[0]
+	resultText := s.fileInfoText(*result)
+	resultText += formatWarnings(warnings)
+
+	return &CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: resultText,
+			},
+		},
+		StructuredContent: newFileEntry(*result),
+	}, nil
+}
+
+// fileInfoText 生成 get_file_info 的文本结果（不含警告）
+func (s *MCPEverythingServer) fileInfoText(result SearchResult) string {
 	resultText := fmt.Sprintf("文件信息: %s\n\n", result.Path)
 	resultText += fmt.Sprintf("类型: %s\n", result.Type)
 	if result.Size > 0 {
@@ -1806,18 +3214,58 @@ func (s *MCPEverythingServer) handleGetFileInfo(
 	} else if result.Type == "file" {
 		resultText += "大小: 0 字节 (空文件)\n"
 	}
-	if result.Date != "" {
-		resultText += fmt.Sprintf("修改日期: %s\n", result.Date)
+	if !result.DateModified.IsZero() {
+		resultText += fmt.Sprintf("修改日期: %s\n", s.displayTime(result.DateModified))
+	}
+	if !result.DateCreated.IsZero() {
+		resultText += fmt.Sprintf("创建日期: %s\n", s.displayTime(result.DateCreated))
+	}
+	if !result.DateAccessed.IsZero() {
+		resultText += fmt.Sprintf("访问日期: %s\n", s.displayTime(result.DateAccessed))
+	}
+	if !result.DateRun.IsZero() {
+		resultText += fmt.Sprintf("最近运行: %s\n", s.displayTime(result.DateRun))
+	}
+	if result.Attributes != 0 {
+		resultText += fmt.Sprintf("属性: %s\n", result.Attributes)
 	}
 	resultText += fmt.Sprintf("完整路径: %s\n", result.FullPath)
+	if result.Source != "" {
+		resultText += fmt.Sprintf("来源: %s\n", result.Source)
+	}
+	return resultText
+}
+
+// handleCacheStats 处理缓存统计请求
+func (s *MCPEverythingServer) handleCacheStats(
+	ctx context.Context,
+	args map[string]interface{},
+) (*CallToolResult, error) {
+	stats := s.cache.Stats()
 
-	return &mcp.CallToolResult{
+	hitRate := 0.0
+	if lookups := stats.Hits + stats.Misses + stats.Coalesced; lookups > 0 {
+		hitRate = float64(stats.Hits+stats.Coalesced) / float64(lookups) * 100
+	}
+
+	resultText := "搜索缓存统计:\n\n"
+	resultText += fmt.Sprintf("命中: %d\n", stats.Hits)
+	resultText += fmt.Sprintf("未命中: %d\n", stats.Misses)
+	resultText += fmt.Sprintf("合并的并发请求: %d\n", stats.Coalesced)
+	resultText += fmt.Sprintf("命中率: %.1f%%\n", hitRate)
+	resultText += fmt.Sprintf("淘汰: %d\n", stats.Evictions)
+	resultText += fmt.Sprintf("条目数: %d\n", stats.Entries)
+	resultText += fmt.Sprintf("内存占用: %s / %s\n", formatFileSize(stats.Bytes), formatFileSize(stats.MaxBytes))
+	resultText += fmt.Sprintf("有效期: %s\n", stats.TTL)
+
+	return &CallToolResult{
 		Content: []mcp.Content{
 			mcp.TextContent{
 				Type: "text",
 				Text: resultText,
 			},
 		},
+		StructuredContent: stats,
 	}, nil
 }
 
@@ -1836,52 +3284,122 @@ func formatFileSize(size int64) string {
 }
 
 func main() {
-	// 从环境变量读取配置
-	baseURL := os.Getenv("EVERYTHING_BASE_URL")
-	if baseURL == "" {
-		baseURL = "http://localhost"
+	// 传输方式：stdio（默认，由 MCP 客户端启动进程）或 http（Streamable HTTP，多个客户端共享）
+	defaultTransport := os.Getenv("EVERYTHING_TRANSPORT")
+	if defaultTransport == "" {
+		defaultTransport = "stdio"
+	}
+	defaultListen := os.Getenv("EVERYTHING_LISTEN")
+	if defaultListen == "" {
+		defaultListen = "127.0.0.1:8080"
+	}
+	transport := flag.String("transport", defaultTransport, "传输方式: stdio 或 http")
+	listen := flag.String("listen", defaultListen, "http 传输的监听地址")
+	allowedOrigins := flag.String("allowed-origins", os.Getenv("EVERYTHING_ALLOWED_ORIGINS"),
+		"http 传输允许的浏览器来源（Origin），逗号分隔；本机地址总是允许，* 表示允许所有来源")
+	defaultSessionIdle := defaultSessionIdleTimeout
+	if v, err := time.ParseDuration(os.Getenv("EVERYTHING_SESSION_IDLE_TIMEOUT")); err == nil && v > 0 {
+		defaultSessionIdle = v
+	}
+	sessionIdleTimeout := flag.Duration("session-idle-timeout", defaultSessionIdle,
+		"http 传输的会话空闲超时，超时后结束会话并取消它的资源订阅")
+	// 配置文件：--config 指定，未指定时在 XDG 配置目录中查找
+	configPath := flag.String("config", os.Getenv("EVERYTHING_CONFIG"),
+		"配置文件路径（YAML、TOML 或 JSON），未指定时在 XDG 配置目录中查找 everything-mcp/config.yaml 等")
+	profile := flag.String("profile", os.Getenv("EVERYTHING_PROFILE"), "使用配置文件中的 profile")
+	printConfigOnly := flag.Bool("print-config", false, "输出生效的配置（密码已隐藏）后退出")
+	// 其余参数与配置文件中的配置项一一对应，显式指定时覆盖配置文件和环境变量
+	registerConfigFlags(flag.CommandLine, DefaultConfig())
+	flag.Parse()
+
+	if *transport != "stdio" && *transport != "http" {
+		fmt.Fprintf(os.Stderr, "不支持的传输方式: %s（可选: stdio, http）\n", *transport)
+		os.Exit(2)
+	}
+
+	loader := &configLoader{path: *configPath, profile: *profile, flags: make(map[string]string)}
+	if loader.path == "" {
+		loader.path = findConfigFile()
+	}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "transport", "listen", "allowed-origins", "session-idle-timeout", "config", "profile", "print-config":
+		default:
+			loader.flags[f.Name] = f.Value.String()
+		}
+	})
+	config, err := loader.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+		os.Exit(2)
 	}
 
-	port := 80
-	if portStr := os.Getenv("EVERYTHING_PORT"); portStr != "" {
-		if p, err := strconv.Atoi(portStr); err == nil {
-			port = p
+	if *printConfigOnly {
+		if loader.path != "" {
+			fmt.Printf("# 配置文件: %s\n", loader.path)
 		}
+		if err := printConfig(os.Stdout, config); err != nil {
+			fmt.Fprintf(os.Stderr, "输出配置失败: %v\n", err)
+			os.Exit(1)
+		}
+		return
 	}
 
-	username := os.Getenv("EVERYTHING_USERNAME")
-	password := os.Getenv("EVERYTHING_PASSWORD")
-
-	config := &EverythingConfig{
-		BaseURL:  baseURL,
-		Port:     port,
-		Username: username,
-		Password: password,
-		Timeout:  10 * time.Second,
+	// 创建并启动服务器，收到 SIGHUP 或配置文件被修改时重新加载配置
+	initial, err := NewMCPEverythingServer(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "创建服务器失败: %v\n", err)
+		os.Exit(2)
 	}
-
-	// 创建并启动服务器
-	server := NewMCPEverythingServer(config)
+	server := newReloadingServer(initial, loader)
 
 	// 注意：不要输出到 stderr，因为 MCP 协议使用 stdio 进行 JSON-RPC 通信
 	// 输出到 stderr 可能会干扰通信
 	// 如果需要调试，可以通过环境变量控制
 	if os.Getenv("EVERYTHING_DEBUG") == "true" {
 		fmt.Fprintf(os.Stderr, "Everything MCP Server 启动中...\n")
+		if config.Backend == backendLocal {
+			fmt.Fprintf(os.Stderr, "本地索引根目录: %s\n", strings.Join(config.LocalRoots, ", "))
+		}
+		for _, b := range config.Backends {
+			if b.Type == backendLocal {
+				fmt.Fprintf(os.Stderr, "联合搜索后端 %s: 本地 %s\n", b.Name, strings.Join(b.LocalRoots, ", "))
+			} else {
+				fmt.Fprintf(os.Stderr, "联合搜索后端 %s: %s:%d\n", b.Name, b.BaseURL, b.Port)
+			}
+		}
 		fmt.Fprintf(os.Stderr, "Everything HTTP API: %s:%d\n", config.BaseURL, config.Port)
-		if username != "" {
-			fmt.Fprintf(os.Stderr, "用户名已配置: %s\n", username)
+		if config.CacheTTL > 0 {
+			fmt.Fprintf(os.Stderr, "搜索缓存: 有效期 %s，上限 %d MB\n", config.CacheTTL, config.CacheMaxBytes>>20)
+		}
+		if config.Username != "" {
+			fmt.Fprintf(os.Stderr, "用户名已配置: %s\n", config.Username)
 		} else {
 			fmt.Fprintf(os.Stderr, "警告: 未配置用户名\n")
 		}
-		if password != "" {
-			fmt.Fprintf(os.Stderr, "密码已配置: %s\n", strings.Repeat("*", len(password)))
+		if config.Password != "" {
+			fmt.Fprintf(os.Stderr, "密码已配置: %s\n", strings.Repeat("*", len(config.Password)))
 		} else {
 			fmt.Fprintf(os.Stderr, "警告: 未配置密码\n")
 		}
 	}
 
-	if err := server.Serve(); err != nil {
+	if *transport == "http" {
+		// token 只能通过环境变量设置，避免出现在进程列表中
+		options := httpOptions{
+			Token:              os.Getenv("EVERYTHING_HTTP_TOKEN"),
+			SessionIdleTimeout: *sessionIdleTimeout,
+		}
+		for _, origin := range strings.Split(*allowedOrigins, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				options.AllowedOrigins = append(options.AllowedOrigins, origin)
+			}
+		}
+		err = server.ServeStreamableHTTP(*listen, options)
+	} else {
+		err = server.Serve()
+	}
+	if err != nil {
 		// 错误信息输出到 stderr 是安全的
 		log.Printf("服务器错误: %v\n", err)
 		os.Exit(1)
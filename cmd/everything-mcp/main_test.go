@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newLocalTestServer(t *testing.T, root string) *MCPEverythingServer {
+	t.Helper()
+	config := DefaultConfig()
+	config.Backend = backendLocal
+	config.LocalRoots = []string{root}
+	s, err := NewMCPEverythingServer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestGetFileInfoExactPath(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "foo.txt")
+	sibling := filepath.Join(dir, "foo.txt.bak")
+	if err := os.WriteFile(target, []byte("foo"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// 前缀相同的文件是只读的，属性不能混到 foo.txt 上
+	if err := os.WriteFile(sibling, []byte("backup"), 0o444); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newLocalTestServer(t, dir)
+	result, err := s.handleGetFileInfo(context.Background(), map[string]interface{}{"path": target})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IsError {
+		t.Fatalf("get_file_info 返回错误: %+v", result.Content)
+	}
+	entry, ok := result.StructuredContent.(FileEntry)
+	if !ok {
+		t.Fatalf("StructuredContent 类型为 %T", result.StructuredContent)
+	}
+	if entry.Path != target {
+		t.Errorf("Path = %s，期望 %s", entry.Path, target)
+	}
+	if entry.Size == nil || *entry.Size != 3 {
+		t.Errorf("Size = %v，期望 3", entry.Size)
+	}
+	if entry.Attributes == nil || entry.Attributes.ReadOnly {
+		t.Errorf("Attributes = %+v，期望不是只读", entry.Attributes)
+	}
+}
+
+func TestGetFileInfoNotFound(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "foo.txt.bak"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newLocalTestServer(t, dir)
+	result, err := s.handleGetFileInfo(context.Background(), map[string]interface{}{"path": filepath.Join(dir, "foo.txt")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.IsError {
+		t.Errorf("只有 foo.txt.bak 时查询 foo.txt 应返回不存在，实际为 %+v", result.StructuredContent)
+	}
+}
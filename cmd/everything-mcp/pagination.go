@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+)
+
+// pageCursor 分页游标的内容
+// 游标对客户端是不透明的，只能原样传回；绑定搜索参数的哈希，防止用于其他查询
+type pageCursor struct {
+	Query  string `json:"q"`
+	Offset int    `json:"o"`
+}
+
+// queryHash 计算搜索参数（不含分页位置）的短哈希，用于校验游标
+func queryHash(opts SearchOptions) string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s\x00%s\x00%t%t%t%t%t", opts.Query, opts.Sort, opts.MatchCase, opts.MatchWholeWord, opts.MatchPath, opts.MatchDiacritics, opts.Regex)
+	return fmt.Sprintf("%x", h.Sum64())
+}
+
+// encodeCursor 生成指向 offset 的游标
+func encodeCursor(opts SearchOptions, offset int) string {
+	data, _ := json.Marshal(pageCursor{Query: queryHash(opts), Offset: offset})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor 解析游标，并校验它属于同一组搜索参数
+func decodeCursor(cursor string, opts SearchOptions) (int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, fmt.Errorf("无效的 cursor")
+	}
+	var c pageCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Offset < 0 {
+		return 0, fmt.Errorf("无效的 cursor")
+	}
+	if c.Query != queryHash(opts) {
+		return 0, fmt.Errorf("cursor 与当前查询不匹配，请使用相同的参数翻页")
+	}
+	return c.Offset, nil
+}
+
+// pageOffset 从工具参数中解析起始位置：cursor 优先，其次是 offset
+func pageOffset(args map[string]interface{}, opts SearchOptions) (int, error) {
+	if cursor, ok := args["cursor"].(string); ok && cursor != "" {
+		return decodeCursor(cursor, opts)
+	}
+	if o, ok := args["offset"].(float64); ok {
+		if o < 0 {
+			return 0, fmt.Errorf("offset 不能为负数")
+		}
+		return int(o), nil
+	}
+	return 0, nil
+}
+
+// nextCursor 如果还有更多结果，返回下一页的游标，否则返回空字符串
+func nextCursor(opts SearchOptions, count, total int) string {
+	if count == 0 || opts.Offset+count >= total {
+		return ""
+	}
+	return encodeCursor(opts, opts.Offset+count)
+}
+
+// formatPageInfo 生成分页信息文本，附加在结果列表之后
+func formatPageInfo(offset, count, total int, cursor string) string {
+	if count == 0 {
+		if total > 0 {
+			return fmt.Sprintf("共 %d 个匹配，offset %d 之后没有更多结果\n", total, offset)
+		}
+		return ""
+	}
+	text := fmt.Sprintf("共 %d 个匹配，当前显示第 %d-%d 个\n", total, offset+1, offset+count)
+	if cursor != "" {
+		text += fmt.Sprintf("还有更多结果，使用 cursor 参数获取下一页: %s\n", cursor)
+	}
+	return text
+}
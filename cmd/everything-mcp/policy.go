@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// defaultDenyGlobs 默认隐藏的路径：密钥、凭据和浏览器配置文件
+var defaultDenyGlobs = []string{
+	".ssh",
+	".gnupg",
+	".aws",
+	".azure",
+	".kube",
+	"id_rsa*",
+	"id_dsa*",
+	"id_ecdsa*",
+	"id_ed25519*",
+	"*.kdbx",
+	"*.pfx",
+	"*.p12",
+	".env",
+	".netrc",
+	".git-credentials",
+	"**/AppData/Local/Google/Chrome/User Data",
+	"**/AppData/Local/Microsoft/Edge/User Data",
+	"**/AppData/Roaming/Mozilla/Firefox/Profiles",
+	"**/AppData/Local/Microsoft/Credentials",
+	"**/AppData/Roaming/Microsoft/Credentials",
+	"**/AppData/Roaming/Microsoft/Protect",
+}
+
+// PathPolicy 路径访问策略：只允许 roots 下的路径（roots 为空时不限制），
+// 并隐藏匹配 deny 规则的路径。路径比较不区分大小写，\ 和 / 视为相同
+type PathPolicy struct {
+	roots []string // 原样保留的根目录，用于生成查询
+	// normalizedRoots 规范化后的根目录，用于比较
+	normalizedRoots []string
+	deny            []*regexp.Regexp
+}
+
+// NewPathPolicy 创建访问策略，roots 和 denyGlobs 都为空时返回 nil（不限制）
+func NewPathPolicy(roots, denyGlobs []string) (*PathPolicy, error) {
+	p := &PathPolicy{}
+	for _, root := range roots {
+		if root = strings.TrimSpace(root); root == "" {
+			continue
+		}
+		if strings.Contains(root, `"`) {
+			return nil, fmt.Errorf("allowed_roots: 无效的路径: %s", root)
+		}
+		p.roots = append(p.roots, root)
+		p.normalizedRoots = append(p.normalizedRoots, normalizePolicyPath(root))
+	}
+	for _, glob := range denyGlobs {
+		if glob = strings.TrimSpace(glob); glob == "" {
+			continue
+		}
+		re, err := compileDenyGlob(glob)
+		if err != nil {
+			return nil, fmt.Errorf("deny_globs: 无效的规则 %q: %w", glob, err)
+		}
+		p.deny = append(p.deny, re)
+	}
+	if len(p.roots) == 0 && len(p.deny) == 0 {
+		return nil, nil
+	}
+	return p, nil
+}
+
+// normalizePolicyPath 统一使用 / 作为分隔符，去掉末尾的分隔符（根目录 / 除外）
+func normalizePolicyPath(path string) string {
+	path = strings.ReplaceAll(path, `\`, "/")
+	if trimmed := strings.TrimRight(path, "/"); trimmed != "" {
+		return trimmed
+	}
+	return path
+}
+
+// compileDenyGlob 将 deny 规则编译为正则表达式
+// 不含 / 的规则匹配路径中的任意一级名称，例如 .ssh、id_rsa*；
+// 含 / 的规则从路径开头匹配，** 匹配任意多级目录，例如 C:/Users/*/AppData、**/.config/gh
+// 匹配一个目录时，其中的所有内容也被隐藏
+func compileDenyGlob(glob string) (*regexp.Regexp, error) {
+	glob = strings.Trim(strings.ReplaceAll(glob, `\`, "/"), "/")
+	if glob == "" {
+		return nil, fmt.Errorf("规则为空")
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(glob); {
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 3
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i += 2
+		case glob[i] == '*':
+			b.WriteString("[^/]*")
+			i++
+		case glob[i] == '?':
+			b.WriteString("[^/]")
+			i++
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+			i++
+		}
+	}
+
+	if strings.Contains(glob, "/") {
+		return regexp.Compile("(?i)^" + b.String() + "(/.*)?$")
+	}
+	return regexp.Compile("(?i)(^|/)" + b.String() + "(/|$)")
+}
+
+// inRoots 判断路径是否是某个根目录或在其中
+func (p *PathPolicy) inRoots(path string) bool {
+	if len(p.normalizedRoots) == 0 {
+		return true
+	}
+	path = normalizePolicyPath(path)
+	for _, root := range p.normalizedRoots {
+		if strings.EqualFold(path, root) {
+			return true
+		}
+		prefix := root
+		if !strings.HasSuffix(prefix, "/") {
+			prefix += "/"
+		}
+		if len(path) > len(prefix) && strings.EqualFold(path[:len(prefix)], prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowed 判断路径是否允许访问；p 为 nil 时总是允许
+func (p *PathPolicy) Allowed(path string) bool {
+	if p == nil {
+		return true
+	}
+	if !p.inRoots(path) {
+		return false
+	}
+	normalized := normalizePolicyPath(path)
+	for _, re := range p.deny {
+		if re.MatchString(normalized) {
+			return false
+		}
+	}
+	return true
+}
+
+// Roots 返回配置的根目录
+func (p *PathPolicy) Roots() []string {
+	if p == nil {
+		return nil
+	}
+	return p.roots
+}
+
+// scopeQuery 在查询后追加限制到根目录的条件：path:"C:\A\"|path:"D:\B\"
+// 追加的条件与原查询是 AND 关系（Everything 中 | 的优先级高于空格）；
+// 原查询以运算符结尾或引号、分组不匹配时，追加的条件会与原查询的一部分组合，因此返回错误
+func (p *PathPolicy) scopeQuery(query string) (string, error) {
+	if len(p.roots) == 0 {
+		return query, nil
+	}
+	if err := checkQueryClosed(query); err != nil {
+		return "", err
+	}
+	terms := make([]string, 0, len(p.roots))
+	for _, root := range p.roots {
+		sep := "/"
+		if strings.Contains(root, `\`) || (len(root) >= 2 && root[1] == ':') {
+			sep = `\`
+		}
+		if !strings.HasSuffix(root, sep) {
+			root += sep
+		}
+		terms = append(terms, fmt.Sprintf(`path:"%s"`, root))
+	}
+	return strings.TrimSpace(query + " " + strings.Join(terms, "|")), nil
+}
+
+// checkQueryClosed 检查查询在末尾是完整的：引号和 < > 分组都已闭合，且不以 |、!、OR、AND、NOT 结尾
+// < 只有出现在条件开头时才是分组（size:<1mb 中的不是），> 只有出现在条件末尾且有未闭合的分组时才是
+func checkQueryClosed(query string) error {
+	inQuotes, depth := false, 0
+	termStart := true
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		if c == '"' {
+			inQuotes = !inQuotes
+			termStart = false
+			continue
+		}
+		if inQuotes {
+			continue
+		}
+		switch {
+		case c == ' ' || c == '\t' || c == '|' || c == '!':
+			termStart = true
+			continue
+		case c == '<' && termStart:
+			depth++
+			continue
+		case c == '>' && depth > 0 && (i == len(query)-1 || strings.IndexByte(" \t|>", query[i+1]) >= 0):
+			depth--
+		}
+		termStart = false
+	}
+	if inQuotes {
+		return fmt.Errorf("查询中的引号不匹配")
+	}
+	if depth > 0 {
+		return fmt.Errorf("查询中的 < > 分组不匹配")
+	}
+
+	trimmed := strings.TrimSpace(query)
+	if strings.HasSuffix(trimmed, "|") || strings.HasSuffix(trimmed, "!") {
+		return fmt.Errorf("查询不能以运算符结尾: %s", trimmed)
+	}
+	if fields := strings.Fields(trimmed); len(fields) > 0 {
+		switch fields[len(fields)-1] {
+		case "OR", "AND", "NOT":
+			return fmt.Errorf("查询不能以运算符结尾: %s", trimmed)
+		}
+	}
+	return nil
+}
+
+// PolicySearcher 在 EverythingSearcher 外层应用 PathPolicy：
+// 查询限制在根目录中，返回前去掉不允许访问的结果，并记录隐藏的数量
+type PolicySearcher struct {
+	next   EverythingSearcher
+	policy *PathPolicy
+}
+
+// NewPolicySearcher 创建应用访问策略的搜索后端
+func NewPolicySearcher(next EverythingSearcher, policy *PathPolicy) *PolicySearcher {
+	return &PolicySearcher{next: next, policy: policy}
+}
+
+// Search 执行限制在根目录中的搜索，并过滤结果
+// 正则表达式搜索无法追加条件，只过滤结果
+func (ps *PolicySearcher) Search(ctx context.Context, opts SearchOptions) (*SearchResponse, error) {
+	if !opts.Regex {
+		query, err := ps.policy.scopeQuery(opts.Query)
+		if err != nil {
+			return nil, err
+		}
+		opts.Query = query
+	}
+	response, err := ps.next.Search(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := *response
+	filtered.Results = make([]SearchResult, 0, len(response.Results))
+	redacted := 0
+	for _, result := range response.Results {
+		if ps.policy.Allowed(result.Path) {
+			filtered.Results = append(filtered.Results, result)
+		} else {
+			redacted++
+		}
+	}
+	if redacted > 0 {
+		filtered.Redacted += redacted
+		filtered.Warnings = append(append([]string(nil), response.Warnings...),
+			fmt.Sprintf("访问策略隐藏了 %d 个结果", redacted))
+	}
+	return &filtered, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import "testing"
+
+func TestScopeQuery(t *testing.T) {
+	policy, err := NewPathPolicy([]string{`C:\A`, "/srv/data/"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const scope = `path:"C:\A\"|path:"/srv/data/"`
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", scope},
+		{"foo", "foo " + scope},
+		{"foo | bar", "foo | bar " + scope},
+		{`"a | b"`, `"a | b" ` + scope},
+		{`"ends with |"`, `"ends with |" ` + scope},
+		{`"say "quot:"hi"quot:""`, `"say "quot:"hi"quot:"" ` + scope},
+		{"<foo|bar> baz", "<foo|bar> baz " + scope},
+		{"<<a b>|c>", "<<a b>|c> " + scope},
+		{"size:>100mb dm:<today", "size:>100mb dm:<today " + scope},
+		{"!foo", "!foo " + scope},
+		{"black or white", "black or white " + scope},
+	}
+	for _, tt := range tests {
+		got, err := policy.scopeQuery(tt.query)
+		if err != nil {
+			t.Errorf("scopeQuery(%q) 返回错误: %v", tt.query, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("scopeQuery(%q) = %s，期望 %s", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestScopeQueryRejectsOpenQueries(t *testing.T) {
+	policy, err := NewPathPolicy([]string{`C:\A`}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, query := range []string{
+		"foo |",
+		"foo|",
+		"foo !",
+		"foo OR",
+		"foo AND ",
+		"NOT",
+		`foo "bar`,
+		`"`,
+		"<foo",
+		"<foo|bar",
+		"<a <b> c",
+	} {
+		if got, err := policy.scopeQuery(query); err == nil {
+			t.Errorf("scopeQuery(%q) = %s，期望返回错误", query, got)
+		}
+	}
+}
+
+func TestScopeQueryWithoutRoots(t *testing.T) {
+	policy, err := NewPathPolicy(nil, []string{"**/.ssh/**"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 只有 deny_globs 时不追加条件，也不检查查询
+	if got, err := policy.scopeQuery("foo |"); err != nil || got != "foo |" {
+		t.Errorf(`scopeQuery("foo |") = %q, %v`, got, err)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// clientSession 处理一条客户端消息所需的会话状态
+// stdio 进程只有一个会话；HTTP 传输中每个 POST 请求会带上自己的通知通道
+type clientSession struct {
+	// requests 该会话正在处理的请求，用于响应 notifications/cancelled
+	requests *inflightRequests
+	// notify 向客户端发送服务器主动发起的消息（例如进度通知），为 nil 时丢弃
+	notify func(msg []byte)
+	// subscriber 会话的资源订阅者，为 nil 时（例如 initialize 请求）不能订阅资源
+	subscriber *subscriber
+}
+
+// progressKey 在 context 中保存进度通知状态的键
+type progressKey struct{}
+
+// progressReporter 一个携带 _meta.progressToken 的请求的进度通知状态
+type progressReporter struct {
+	token  interface{}
+	notify func(msg []byte)
+}
+
+// withProgress 如果请求携带了 progressToken，在 context 中记录进度通知通道
+func withProgress(ctx context.Context, token interface{}, notify func(msg []byte)) context.Context {
+	if token == nil || notify == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, progressKey{}, &progressReporter{token: token, notify: notify})
+}
+
+// progressTokenFromParams 从请求参数的 _meta 中提取 progressToken，没有时返回 nil
+// token 保留原始 JSON，通知中原样返回（字符串或数字）
+func progressTokenFromParams(params json.RawMessage) interface{} {
+	var p struct {
+		Meta struct {
+			ProgressToken json.RawMessage `json:"progressToken"`
+		} `json:"_meta"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil || !isValidID(p.Meta.ProgressToken) || string(p.Meta.ProgressToken) == "null" {
+		return nil
+	}
+	return p.Meta.ProgressToken
+}
+
+// reportProgress 发送 notifications/progress，请求未携带 progressToken 时不做任何事
+// total <= 0 表示总量未知
+func reportProgress(ctx context.Context, progress, total int, message string) {
+	reporter, ok := ctx.Value(progressKey{}).(*progressReporter)
+	if !ok || ctx.Err() != nil {
+		return
+	}
+
+	params := map[string]interface{}{
+		"progressToken": reporter.token,
+		"progress":      progress,
+	}
+	if total > 0 {
+		params["total"] = total
+	}
+	if message != "" {
+		params["message"] = message
+	}
+
+	notification, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  "notifications/progress",
+		"params":  params,
+	})
+	reporter.notify(notification)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// PromptDefinition 一个参数化的提示词：模板中的 {{参数名}} 替换为 prompts/get 提供的参数值
+type PromptDefinition struct {
+	Name        string
+	Description string
+	Arguments   []PromptArgumentDefinition
+	// Template 提示词正文，作为一条用户消息返回
+	Template string
+	// Tools 提示词用到的工具，其中任何一个被禁用时提示词不出现在列表中
+	Tools []string
+}
+
+// PromptArgumentDefinition 提示词的一个参数
+type PromptArgumentDefinition struct {
+	Name        string
+	Description string
+	Required    bool
+	// Default 未提供参数时使用的值
+	Default string
+}
+
+// promptPlaceholder 模板中的参数占位符，例如 {{service}}
+var promptPlaceholder = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_]+)\s*\}\}`)
+
+// builtinPrompts 内置的提示词，配置文件中同名的提示词会替换它们
+var builtinPrompts = []PromptDefinition{
+	{
+		Name:        "find_newest_log",
+		Description: "查找某个服务最新的日志文件并查看它的详细信息",
+		Arguments: []PromptArgumentDefinition{
+			{Name: "service", Description: "服务名称，例如 nginx、myapp", Required: true},
+			{Name: "days", Description: "只查找最近多少天内修改的日志，默认 7", Default: "7"},
+		},
+		Template: `找到服务 {{service}} 最新的日志文件。
+
+1. 调用 search_recent_files，参数 days 为 {{days}}，query 为 "{{service}} ext:log;txt;out;err"，sort 为 date_modified（最新的在前）。
+2. 如果没有结果，改用 search_files，query 为 "{{service}} ext:log"，sort 为 date_modified，看看更早的日志。
+3. 对排在第一位的文件调用 get_file_info，确认它的大小和修改时间。
+
+回答时给出最新日志的完整路径、大小和修改时间，并列出其他几个较新的候选文件。`,
+		Tools: []string{"search_recent_files", "search_files", "get_file_info"},
+	},
+	{
+		Name:        "clean_up_large_downloads",
+		Description: "找出下载目录中占用空间最多的文件，整理出可以清理的候选列表",
+		Arguments: []PromptArgumentDefinition{
+			{Name: "path", Description: "下载目录，例如 C:\\Users\\me\\Downloads", Required: true},
+			{Name: "min_size", Description: "只关注大于这个大小的文件，默认 100MB", Default: "100MB"},
+		},
+		Template: `帮我清理下载目录 {{path}} 中的大文件。
+
+1. 调用 search_large_files，参数 path 为 "{{path}}"，min_size 为 "{{min_size}}"，sort 为 size（最大的在前）。
+2. 对看起来是重复下载的文件（例如 "setup (1).exe"），调用 search_duplicate_names，参数 filename 为原始文件名，确认是否还有其他副本。
+3. 按类型归类结果（安装包、压缩包、视频、镜像等），标出重复的文件和很久没有修改的文件。
+
+不要删除任何文件。列出建议清理的文件（完整路径和大小）以及可以释放的总空间，由我来决定删除哪些。`,
+		Tools: []string{"search_large_files", "search_duplicate_names"},
+	},
+	{
+		Name:        "locate_project_configs",
+		Description: "找出一个项目中的配置文件（构建、依赖、CI、容器、编辑器等）",
+		Arguments: []PromptArgumentDefinition{
+			{Name: "path", Description: "项目根目录，例如 D:\\src\\myapp", Required: true},
+		},
+		Template: `找出项目 {{path}} 中的配置文件。
+
+1. 调用 search_by_path，参数 path 为 "{{path}}"，依次使用以下 query 搜索：
+   - "package.json|go.mod|Cargo.toml|pyproject.toml|requirements*.txt|pom.xml|build.gradle*|*.csproj|*.sln|Makefile|CMakeLists.txt"
+   - "Dockerfile*|docker-compose*.yml|*.dockerfile"
+   - ".github\*.yml|.gitlab-ci.yml|azure-pipelines.yml|Jenkinsfile"
+   - "*.yaml|*.yml|*.toml|*.ini|*.conf"
+2. 需要时对关键文件调用 get_file_info 查看修改时间。
+
+按用途分组列出找到的配置文件（构建和依赖、容器、CI、应用配置、编辑器和工具），忽略 node_modules、.git 和构建输出目录中的文件。`,
+		Tools: []string{"search_by_path", "get_file_info"},
+	},
+}
+
+// newPromptSet 合并内置提示词和配置文件中的提示词，并检查配置文件中的定义
+func newPromptSet(config *EverythingConfig) ([]PromptDefinition, error) {
+	prompts := append([]PromptDefinition(nil), builtinPrompts...)
+	seen := make(map[string]bool)
+	for _, p := range config.Prompts {
+		if err := validatePrompt(p); err != nil {
+			return nil, fmt.Errorf("prompts: %w", err)
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("prompts: 提示词名称重复: %s", p.Name)
+		}
+		seen[p.Name] = true
+
+		replaced := false
+		for i := range prompts {
+			if prompts[i].Name == p.Name {
+				prompts[i] = p
+				replaced = true
+			}
+		}
+		if !replaced {
+			prompts = append(prompts, p)
+		}
+	}
+	return prompts, nil
+}
+
+// validatePrompt 检查提示词定义：名称和模板不能为空，参数不能重复，模板只能引用定义了的参数
+func validatePrompt(p PromptDefinition) error {
+	if p.Name == "" {
+		return fmt.Errorf("提示词缺少 name")
+	}
+	if strings.TrimSpace(p.Template) == "" {
+		return fmt.Errorf("提示词 %s 缺少 template", p.Name)
+	}
+	args := make(map[string]bool, len(p.Arguments))
+	for _, arg := range p.Arguments {
+		if arg.Name == "" {
+			return fmt.Errorf("提示词 %s 的参数缺少 name", p.Name)
+		}
+		if args[arg.Name] {
+			return fmt.Errorf("提示词 %s 的参数重复: %s", p.Name, arg.Name)
+		}
+		args[arg.Name] = true
+	}
+	for _, m := range promptPlaceholder.FindAllStringSubmatch(p.Template, -1) {
+		if !args[m[1]] {
+			return fmt.Errorf("提示词 %s 的模板引用了未定义的参数: %s", p.Name, m[1])
+		}
+	}
+	for _, tool := range p.Tools {
+		if !isKnownTool(tool) {
+			return fmt.Errorf("提示词 %s: 未知的工具: %s", p.Name, tool)
+		}
+	}
+	return nil
+}
+
+// promptEnabled 提示词用到的工具是否全部启用
+func (s *MCPEverythingServer) promptEnabled(p PromptDefinition) bool {
+	for _, tool := range p.Tools {
+		if !s.tools[tool] {
+			return false
+		}
+	}
+	return true
+}
+
+// handleListPrompts 处理提示词列表请求
+func (s *MCPEverythingServer) handleListPrompts() *mcp.ListPromptsResult {
+	result := &mcp.ListPromptsResult{Prompts: []mcp.Prompt{}}
+	for _, p := range s.prompts {
+		if !s.promptEnabled(p) {
+			continue
+		}
+		prompt := mcp.Prompt{Name: p.Name, Description: p.Description}
+		for _, arg := range p.Arguments {
+			prompt.Arguments = append(prompt.Arguments, mcp.PromptArgument{
+				Name:        arg.Name,
+				Description: arg.Description,
+				Required:    arg.Required,
+			})
+		}
+		result.Prompts = append(result.Prompts, prompt)
+	}
+	return result
+}
+
+// handleGetPrompt 用参数填充提示词模板
+func (s *MCPEverythingServer) handleGetPrompt(name string, args map[string]string) (*mcp.GetPromptResult, error) {
+	var prompt *PromptDefinition
+	for i := range s.prompts {
+		if s.prompts[i].Name == name && s.promptEnabled(s.prompts[i]) {
+			prompt = &s.prompts[i]
+			break
+		}
+	}
+	if prompt == nil {
+		return nil, invalidParams("未知的提示词: %s", name)
+	}
+
+	values := make(map[string]string, len(prompt.Arguments))
+	for _, arg := range prompt.Arguments {
+		value := strings.TrimSpace(args[arg.Name])
+		if value == "" {
+			if arg.Required {
+				return nil, invalidParams("提示词 %s 缺少参数 %s", name, arg.Name)
+			}
+			value = arg.Default
+		}
+		values[arg.Name] = value
+	}
+
+	text := promptPlaceholder.ReplaceAllStringFunc(prompt.Template, func(m string) string {
+		return values[promptPlaceholder.FindStringSubmatch(m)[1]]
+	})
+	return &mcp.GetPromptResult{
+		Description: prompt.Description,
+		Messages: []mcp.PromptMessage{
+			{
+				Role:    mcp.RoleUser,
+				Content: mcp.TextContent{Type: "text", Text: text},
+			},
+		},
+	}, nil
+}
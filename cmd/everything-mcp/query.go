@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Term Everything 搜索语法中的一个条件
+// 由 Text、Path、Ext、SizeGT 等构造函数生成，值在构造时完成转义或校验
+type Term struct {
+	text string
+	err  error
+}
+
+// Query 由多个条件组成的 Everything 查询，条件之间是 AND 关系
+// 构造过程中的第一个错误会被保留，由 Build 返回
+type Query struct {
+	terms []Term
+}
+
+// NewQuery 创建包含指定条件的查询
+func NewQuery(terms ...Term) Query {
+	return Query{}.And(terms...)
+}
+
+// And 追加条件，返回新的查询（原查询不变）
+func (q Query) And(terms ...Term) Query {
+	result := Query{terms: make([]Term, 0, len(q.terms)+len(terms))}
+	result.terms = append(result.terms, q.terms...)
+	for _, t := range terms {
+		if t.text == "" && t.err == nil {
+			// 空条件（例如可选参数未提供）直接忽略
+			continue
+		}
+		result.terms = append(result.terms, t)
+	}
+	return result
+}
+
+// Build 生成查询字符串，任何条件的值无效时返回错误
+func (q Query) Build() (string, error) {
+	parts := make([]string, 0, len(q.terms))
+	for _, t := range q.terms {
+		if t.err != nil {
+			return "", t.err
+		}
+		parts = append(parts, t.text)
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// String 返回查询字符串，忽略错误，仅用于显示
+func (q Query) String() string {
+	parts := make([]string, 0, len(q.terms))
+	for _, t := range q.terms {
+		if t.err == nil {
+			parts = append(parts, t.text)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// quoteValue 将值转换为 Everything 的字面量：整体放入双引号中，
+// 引号内的空格、|、!、<、> 等都不再是运算符；
+// 值本身包含的双引号通过 quot: 宏表示（先结束引号，再重新开始）
+func quoteValue(value string) string {
+	return `"` + strings.ReplaceAll(value, `"`, `"quot:"`) + `"`
+}
+
+// needsQuote 判断值是否包含 Everything 语法中有特殊含义的字符，不包含时可以不加引号
+func needsQuote(value string) bool {
+	return value == "" || strings.ContainsAny(value, " \t\"|!<>:;&")
+}
+
+// Raw 用户直接提供的 Everything 搜索语法（例如工具的 query 参数），不做转义
+// 空字符串表示没有条件
+func Raw(query string) Term {
+	return Term{text: strings.TrimSpace(query)}
+}
+
+// Text 字面文本，例如文件名或完整路径，总是加引号
+// 空字符串表示没有条件
+func Text(value string) Term {
+	if value == "" {
+		return Term{}
+	}
+	return Term{text: quoteValue(value)}
+}
+
+// Path 限定在指定路径下（path:），空字符串表示没有条件
+func Path(path string) Term {
+	if path == "" {
+		return Term{}
+	}
+	return Term{text: "path:" + quoteValue(path)}
+}
+
+// Parent 限定为指定文件夹的直接子项（parent:），末尾的路径分隔符会被去掉（"/" 本身除外）
+func Parent(dir string) Term {
+	if trimmed := strings.TrimRight(dir, `\/`); trimmed != "" {
+		dir = trimmed
+	} else if dir != "" {
+		dir = dir[:1]
+	}
+	if dir == "" {
+		return Term{err: fmt.Errorf("文件夹路径不能为空")}
+	}
+	return Term{text: "parent:" + quoteValue(dir)}
+}
+
+// Ext 限定扩展名（ext:），多个扩展名之间是 OR 关系；扩展名前的点号会被去掉
+func Ext(exts ...string) Term {
+	values := make([]string, 0, len(exts))
+	quote := false
+	for _, ext := range exts {
+		ext = strings.TrimPrefix(strings.TrimSpace(ext), ".")
+		if ext == "" {
+			return Term{err: fmt.Errorf("扩展名不能为空")}
+		}
+		if strings.ContainsAny(ext, `;"`) {
+			return Term{err: fmt.Errorf("无效的扩展名: %q", ext)}
+		}
+		quote = quote || needsQuote(ext)
+		values = append(values, ext)
+	}
+	if len(values) == 0 {
+		return Term{err: fmt.Errorf("扩展名不能为空")}
+	}
+	// 多个扩展名用 ; 分隔，只有扩展名本身包含特殊字符时才需要加引号
+	value := strings.Join(values, ";")
+	if quote {
+		value = quoteValue(value)
+	}
+	return Term{text: "ext:" + value}
+}
+
+// FileName 只匹配文件（file:），文件名作为字面文本
+func FileName(name string) Term {
+	if name == "" {
+		return Term{err: fmt.Errorf("文件名不能为空")}
+	}
+	return Term{text: "file:" + quoteValue(name)}
+}
+
+// Files 只匹配文件
+func Files() Term { return Term{text: "file:"} }
+
+// Folders 只匹配文件夹
+func Folders() Term { return Term{text: "folder:"} }
+
+// Empty 只匹配空文件夹
+func Empty() Term { return Term{text: "empty:"} }
+
+// Roots 只匹配驱动器根目录
+func Roots() Term { return Term{text: "root:"} }
+
+// sizePattern Everything 支持的大小值：数字加可选单位，或者预定义的大小名称
+var sizePattern = regexp.MustCompile(`(?i)^(\d+(\.\d+)?(b|kb|mb|gb|tb|k|m|g|t)?|empty|tiny|small|medium|large|huge|gigantic)$`)
+
+// sizeTerm 生成大小条件，op 为 ">"、"<" 或 ""（等于）；空字符串表示没有条件
+func sizeTerm(op, size string) Term {
+	// Everything 的大小值中不能有空格，"100 MB" 按 "100MB" 处理
+	value := strings.Join(strings.Fields(size), "")
+	if value == "" {
+		return Term{}
+	}
+	if !sizePattern.MatchString(value) {
+		return Term{err: fmt.Errorf("无效的大小: %q（例如 100MB、1.5GB、1024）", size)}
+	}
+	return Term{text: "size:" + op + value}
+}
+
+// SizeGT 大于指定大小，例如 "100MB"
+func SizeGT(size string) Term { return sizeTerm(">", size) }
+
+// SizeLT 小于指定大小
+func SizeLT(size string) Term { return sizeTerm("<", size) }
+
+// SizeEQ 等于指定大小
+func SizeEQ(size string) Term { return sizeTerm("", size) }
+
+// datePattern Everything 支持的日期值：数字日期（2024-01-01、2024/1/1）或关键词（today、lastweek 等）
+var datePattern = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z\-/.]*$`)
+
+// validDate 校验日期值，防止值中的空格或运算符改变查询含义
+func validDate(value string) error {
+	if !datePattern.MatchString(value) || strings.Contains(value, "..") {
+		return fmt.Errorf("无效的日期: %q（例如 2024-01-01、today、lastweek）", value)
+	}
+	return nil
+}
+
+// dateFunctions 日期类型对应的 Everything 搜索函数
+var dateFunctions = map[string]string{
+	"modified": "dm:",
+	"created":  "dc:",
+}
+
+// DateRange 日期条件，dateType 为 modified 或 created；
+// from 和 to 可以只提供一个，分别表示晚于和早于
+func DateRange(dateType, from, to string) Term {
+	prefix, ok := dateFunctions[dateType]
+	if !ok {
+		return Term{err: fmt.Errorf("不支持的日期类型: %s", dateType)}
+	}
+	for _, value := range []string{from, to} {
+		if value == "" {
+			continue
+		}
+		if err := validDate(value); err != nil {
+			return Term{err: err}
+		}
+	}
+
+	switch {
+	case from != "" && to != "":
+		return Term{text: prefix + from + ".." + to}
+	case from != "":
+		return Term{text: prefix + ">" + from}
+	case to != "":
+		return Term{text: prefix + "<" + to}
+	}
+	return Term{err: fmt.Errorf("至少需要提供开始或结束日期")}
+}
+
+// ModifiedWithinDays 最近 days 天内修改过
+func ModifiedWithinDays(days int) Term {
+	if days <= 0 {
+		return Term{err: fmt.Errorf("days 必须大于 0")}
+	}
+	return Term{text: fmt.Sprintf("dm:last%ddays", days)}
+}
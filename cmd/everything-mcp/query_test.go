@@ -0,0 +1,98 @@
+package main
+
+import "testing"
+
+func TestQueryBuild(t *testing.T) {
+	tests := []struct {
+		name  string
+		query Query
+		want  string
+	}{
+		{"纯文本", NewQuery(Text("report")), `"report"`},
+		{"空格", NewQuery(Text("my report")), `"my report"`},
+		{"嵌入的双引号", NewQuery(Text(`say "hi"`)), `"say "quot:"hi"quot:""`},
+		{"只有双引号", NewQuery(Text(`"`)), `""quot:""`},
+		{"运算符字符", NewQuery(Text(`a|b !c <d> e;f`)), `"a|b !c <d> e;f"`},
+		{"反斜杠", NewQuery(Text(`C:\Users\me\a b.txt`)), `"C:\Users\me\a b.txt"`},
+		{"空文本被忽略", NewQuery(Text(""), Raw("  "), Files()), `file:`},
+		{"原始查询不转义", NewQuery(Raw(" *.log | *.txt ")), `*.log | *.txt`},
+
+		{"path", NewQuery(Path(`D:\My Projects`)), `path:"D:\My Projects"`},
+		{"path 中的双引号", NewQuery(Path(`/tmp/"x"`)), `path:"/tmp/"quot:"x"quot:""`},
+
+		{"parent Windows", NewQuery(Parent(`C:\Users\me`)), `parent:"C:\Users\me"`},
+		{"parent 去掉末尾的反斜杠", NewQuery(Parent(`C:\Users\me\`)), `parent:"C:\Users\me"`},
+		{"parent 去掉末尾的多个分隔符", NewQuery(Parent(`/home/me//`)), `parent:"/home/me"`},
+		{"parent 驱动器根目录", NewQuery(Parent(`C:\`)), `parent:"C:"`},
+		{"parent POSIX 根目录", NewQuery(Parent("/")), `parent:"/"`},
+		{"parent UNC", NewQuery(Parent(`\\server\share\`)), `parent:"\\server\share"`},
+		{"parent 特殊字符", NewQuery(Parent(`C:\a|b <c>`)), `parent:"C:\a|b <c>"`},
+
+		{"ext 单个", NewQuery(Ext("pdf")), `ext:pdf`},
+		{"ext 去掉点号和空白", NewQuery(Ext(" .jpg", "png ")), `ext:jpg;png`},
+		{"ext 含空格时加引号", NewQuery(Ext("tar gz")), `ext:"tar gz"`},
+		{"ext 含运算符时加引号", NewQuery(Ext("a|b", "c")), `ext:"a|b;c"`},
+
+		{"file", NewQuery(FileName(`setup (1).exe`)), `file:"setup (1).exe"`},
+		{"size", NewQuery(SizeGT("100 MB"), SizeLT("1.5gb")), `size:>100MB size:<1.5gb`},
+		{"size 名称", NewQuery(SizeEQ("huge")), `size:huge`},
+		{"日期范围", NewQuery(DateRange("modified", "2024-01-01", "2024/12/31")), `dm:2024-01-01..2024/12/31`},
+		{"开始日期", NewQuery(DateRange("created", "lastweek", "")), `dc:>lastweek`},
+		{"结束日期", NewQuery(DateRange("modified", "", "today")), `dm:<today`},
+		{"最近几天", NewQuery(ModifiedWithinDays(7)), `dm:last7days`},
+		{"组合", NewQuery(Parent(`C:\a b\`), Folders()).And(Text("x")), `parent:"C:\a b" folder: "x"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.query.Build()
+			if err != nil {
+				t.Fatalf("Build() 返回错误: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Build() = %s，期望 %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryBuildErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		term Term
+	}{
+		{"parent 空路径", Parent("")},
+		{"ext 空扩展名", Ext(".")},
+		{"ext 没有扩展名", Ext()},
+		{"ext 包含分号", Ext("a;b")},
+		{"ext 包含双引号", Ext(`a"b`)},
+		{"file 空文件名", FileName("")},
+		{"size 无效单位", SizeGT("100XB")},
+		{"size 注入运算符", SizeGT("1|size:>0")},
+		{"日期包含空格", DateRange("modified", "2024-01-01 | *", "")},
+		{"日期包含范围", DateRange("modified", "2024..2025", "")},
+		{"日期包含引号", DateRange("created", "", `"today"`)},
+		{"不支持的日期类型", DateRange("accessed", "today", "")},
+		{"没有日期", DateRange("modified", "", "")},
+		{"days 为 0", ModifiedWithinDays(0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := NewQuery(Text("ok"), tt.term, Files())
+			if got, err := q.Build(); err == nil {
+				t.Errorf("Build() = %s，期望返回错误", got)
+			}
+			// String 忽略无效的条件，只用于显示
+			if got := q.String(); got != `"ok" file:` {
+				t.Errorf("String() = %s，期望 %s", got, `"ok" file:`)
+			}
+		})
+	}
+}
+
+func TestQueryAndDoesNotModifyReceiver(t *testing.T) {
+	base := NewQuery(Files())
+	_ = base.And(Text("a"))
+	if got, _ := base.Build(); got != "file:" {
+		t.Errorf("And 修改了原查询: %s", got)
+	}
+}
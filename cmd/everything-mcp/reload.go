@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// configWatchInterval 检查配置文件是否被修改的间隔
+const configWatchInterval = 2 * time.Second
+
+// 工具或提示词列表变化时发送给所有客户端的通知
+var (
+	toolsListChangedNotification   = []byte(`{"jsonrpc":"2.0","method":"notifications/tools/list_changed"}`)
+	promptsListChangedNotification = []byte(`{"jsonrpc":"2.0","method":"notifications/prompts/list_changed"}`)
+)
+
+// broadcaster 向所有已连接的客户端发送服务器主动发起的通知
+// stdio 只有一个客户端；HTTP 传输中每个会话通过 GET 打开的 SSE 流接收
+type broadcaster struct {
+	mu    sync.Mutex
+	next  int
+	sinks map[int]func(msg []byte)
+}
+
+// newBroadcaster 创建新的广播器
+func newBroadcaster() *broadcaster {
+	return &broadcaster{sinks: make(map[int]func(msg []byte))}
+}
+
+// subscribe 登记一个客户端的通知通道，返回取消登记的函数
+func (b *broadcaster) subscribe(send func(msg []byte)) (unsubscribe func()) {
+	if b == nil {
+		return func() {}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := b.next
+	b.next++
+	b.sinks[id] = send
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.sinks, id)
+	}
+}
+
+// broadcast 将消息发送给所有登记的客户端
+func (b *broadcaster) broadcast(msg []byte) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	sinks := make([]func(msg []byte), 0, len(b.sinks))
+	for _, send := range b.sinks {
+		sinks = append(sinks, send)
+	}
+	b.mu.Unlock()
+
+	for _, send := range sinks {
+		send(msg)
+	}
+}
+
+// reloadingServer 持有当前生效的 MCPEverythingServer，配置变化时整体替换
+// 每个请求只使用收到请求时生效的那个实例，因此正在处理的请求不受重新加载影响
+type reloadingServer struct {
+	loader  *configLoader
+	current atomic.Pointer[MCPEverythingServer]
+	clients *broadcaster
+	// subscriptions 资源订阅，重新加载后继续使用新的实例轮询
+	subscriptions *resourceSubscriptions
+
+	// mu 串行化重新加载
+	mu sync.Mutex
+}
+
+// newReloadingServer 创建可重新加载配置的服务器，s 为按初始配置创建的实例
+func newReloadingServer(s *MCPEverythingServer, loader *configLoader) *reloadingServer {
+	r := &reloadingServer{loader: loader, clients: newBroadcaster()}
+	r.subscriptions = newResourceSubscriptions(r.current.Load)
+	s.subscriptions = r.subscriptions
+	r.current.Store(s)
+	return r
+}
+
+// Request 使用当前生效的配置处理一个 JSON-RPC 请求
+func (r *reloadingServer) Request(ctx context.Context, method string, params json.RawMessage) (interface{}, error) {
+	return r.current.Load().Request(ctx, method, params)
+}
+
+// reload 重新读取配置并替换后端客户端、工具和提示词设置
+// 新配置无效时保留原来的配置；工具或提示词列表变化时通知所有客户端
+func (r *reloadingServer) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	config, err := r.loader.load()
+	if err != nil {
+		return err
+	}
+	next, err := NewMCPEverythingServer(config)
+	if err != nil {
+		return err
+	}
+	next.subscriptions = r.subscriptions
+
+	prev := r.current.Load()
+	if config.MaxInFlight != prev.config.MaxInFlight {
+		log.Printf("max_in_flight 的修改需要重启服务器才能生效\n")
+	}
+	r.current.Store(next)
+	// 正在处理的请求仍可以使用旧的服务器完成，这里只停止它的后台任务
+	prev.close()
+
+	if os.Getenv("EVERYTHING_DEBUG") == "true" {
+		fmt.Fprintf(os.Stderr, "[DEBUG] 配置已重新加载\n")
+	}
+	if !bytes.Equal(prev.toolListJSON(), next.toolListJSON()) {
+		r.clients.broadcast(toolsListChangedNotification)
+	}
+	if !bytes.Equal(prev.promptListJSON(), next.promptListJSON()) {
+		r.clients.broadcast(promptsListChangedNotification)
+	}
+	return nil
+}
+
+// watch 收到 SIGHUP 或配置文件被修改时重新加载配置，直到 ctx 结束
+func (r *reloadingServer) watch(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	// 没有配置文件时只响应 SIGHUP
+	var tick <-chan time.Time
+	last, _ := statConfigFile(r.loader.path)
+	if r.loader.path != "" {
+		ticker := time.NewTicker(configWatchInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			last, _ = statConfigFile(r.loader.path)
+		case <-tick:
+			// 编辑器保存时文件可能短暂不存在，等它重新出现再比较
+			stamp, err := statConfigFile(r.loader.path)
+			if err != nil || stamp == last {
+				continue
+			}
+			last = stamp
+		}
+		if err := r.reload(); err != nil {
+			log.Printf("重新加载配置失败，继续使用原来的配置: %v\n", err)
+		}
+	}
+}
+
+// configStamp 用于判断配置文件是否被修改
+type configStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// statConfigFile 返回配置文件的修改时间和大小
+func statConfigFile(path string) (configStamp, error) {
+	if path == "" {
+		return configStamp{}, os.ErrNotExist
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return configStamp{}, err
+	}
+	return configStamp{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// toolListJSON 返回 tools/list 的结果，用于判断工具列表是否变化
+func (s *MCPEverythingServer) toolListJSON() []byte {
+	result, err := s.handleListTools(context.Background(), nil)
+	if err != nil {
+		return nil
+	}
+	data, _ := json.Marshal(result)
+	return data
+}
+
+// promptListJSON 返回 prompts/list 的结果，用于判断提示词列表是否变化
+func (s *MCPEverythingServer) promptListJSON() []byte {
+	data, _ := json.Marshal(s.handleListPrompts())
+	return data
+}
+
+// Serve 通过 stdio 启动 MCP 服务器，并在配置变化时重新加载
+func (r *reloadingServer) Serve() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.watch(ctx)
+
+	return serveStdioWithNotificationSupport(r, r.current.Load().config.MaxInFlight, r.clients)
+}
+
+// ServeStreamableHTTP 通过 Streamable HTTP 启动 MCP 服务器，并在配置变化时重新加载
+func (r *reloadingServer) ServeStreamableHTTP(addr string, options httpOptions) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.watch(ctx)
+
+	return serveStreamableHTTP(addr, newHTTPTransport(r, r.clients, options))
+}
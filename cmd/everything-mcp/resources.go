@@ -0,0 +1,382 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 资源 URI：
+//
+//	everything://localhost/C:/Users/me/notes.txt   文件（显示文件信息）
+//	everything://localhost/C:/Users/me/            文件夹（显示目录内容）
+//	everything://search?q=*.log&sort=date_modified 搜索结果
+//
+// 联合搜索时主机名为后端名称，只读取该后端；localhost 表示所有后端
+const (
+	resourceScheme     = "everything"
+	localResourceHost  = "localhost"
+	searchResourceHost = "search"
+)
+
+// ResourceTemplate MCP 资源模板定义（mcp-go v0.1.0 中没有对应的类型）
+type ResourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ListResourceTemplatesResult resources/templates/list 的结果
+type ListResourceTemplatesResult struct {
+	Meta              *mcp.MetaData      `json:"_meta,omitempty"`
+	NextCursor        string             `json:"nextCursor,omitempty"`
+	ResourceTemplates []ResourceTemplate `json:"resourceTemplates"`
+}
+
+// resourceTemplates 服务器提供的资源模板
+var resourceTemplates = []ResourceTemplate{
+	{
+		URITemplate: "everything://{host}/{+path}",
+		Name:        "Everything 文件或文件夹",
+		Description: "文件显示文件信息，文件夹（以 / 结尾或驱动器根目录）显示目录内容。" +
+			"host 为 localhost，联合搜索时也可以是后端名称；path 使用 / 分隔，例如 C:/Users/me/notes.txt",
+		MimeType: "text/plain",
+	},
+	{
+		URITemplate: "everything://search{?q,max_results,sort,order}",
+		Name:        "Everything 搜索结果",
+		Description: "使用 Everything 搜索语法的搜索结果，参数与 search_files 工具相同",
+		MimeType:    "text/plain",
+	},
+}
+
+// 资源与提供相同数据的工具对应，工具被禁用时对应的资源也不可用：
+// 文件夹对应 list_directory，文件对应 get_file_info，搜索结果对应 search_files
+const (
+	folderResourceTool = "list_directory"
+	fileResourceTool   = "get_file_info"
+	searchResourceTool = "search_files"
+)
+
+// requireResourceTool 资源对应的工具被禁用时返回错误
+func (s *MCPEverythingServer) requireResourceTool(tool, uri string) error {
+	if !s.tools[tool] {
+		return invalidParams("工具 %s 已被禁用，不能读取资源: %s", tool, uri)
+	}
+	return nil
+}
+
+// handleListResourceTemplates 返回对应工具启用了的资源模板
+func (s *MCPEverythingServer) handleListResourceTemplates() *ListResourceTemplatesResult {
+	result := &ListResourceTemplatesResult{ResourceTemplates: []ResourceTemplate{}}
+	if s.tools[folderResourceTool] || s.tools[fileResourceTool] {
+		result.ResourceTemplates = append(result.ResourceTemplates, resourceTemplates[0])
+	}
+	if s.tools[searchResourceTool] {
+		result.ResourceTemplates = append(result.ResourceTemplates, resourceTemplates[1])
+	}
+	return result
+}
+
+// handleListResources 列出驱动器（配置了 allowed_roots 时为允许访问的根目录）作为文件夹资源
+// list_directory 被禁用时没有可以读取的文件夹资源，返回空列表
+func (s *MCPEverythingServer) handleListResources(ctx context.Context) (*mcp.ListResourcesResult, error) {
+	result := &mcp.ListResourcesResult{Resources: []mcp.Resource{}}
+	if !s.tools[folderResourceTool] {
+		return result, nil
+	}
+
+	if roots := s.policy.Roots(); len(roots) > 0 {
+		for _, host := range s.resourceHosts() {
+			for _, root := range roots {
+				result.Resources = append(result.Resources, mcp.Resource{
+					URI:         resourceURI(host, root, true),
+					Name:        resourceName(host, root),
+					Description: "允许访问的根目录",
+					MimeType:    "text/plain",
+				})
+			}
+		}
+		return result, nil
+	}
+
+	response, err := s.client.Search(ctx, SearchOptions{Query: NewQuery(Roots()).String(), MaxResults: 100})
+	if err != nil {
+		return nil, fmt.Errorf("获取驱动器列表失败: %v", err)
+	}
+	for _, drive := range response.Results {
+		if !isDrivePath(drive.Path) {
+			continue
+		}
+		host := localResourceHost
+		if drive.Source != "" {
+			host = drive.Source
+		}
+		root := driveRoot(drive.Path)
+		description := "驱动器"
+		if strings.HasPrefix(root, "/") {
+			description = "根目录"
+		}
+		result.Resources = append(result.Resources, mcp.Resource{
+			URI:         resourceURI(host, root, true),
+			Name:        resourceName(host, root),
+			Description: description,
+			MimeType:    "text/plain",
+		})
+	}
+	return result, nil
+}
+
+// resourceHosts 返回资源 URI 中可以使用的主机名：单后端时为 localhost，联合搜索时为各个后端名称
+func (s *MCPEverythingServer) resourceHosts() []string {
+	if s.federated == nil {
+		return []string{localResourceHost}
+	}
+	hosts := make([]string, 0, len(s.federated.backends))
+	for _, b := range s.federated.backends {
+		hosts = append(hosts, b.name)
+	}
+	return hosts
+}
+
+// resourceName 资源的显示名称，联合搜索时带上后端名称
+func resourceName(host, path string) string {
+	if host == localResourceHost {
+		return path
+	}
+	return fmt.Sprintf("[%s] %s", host, path)
+}
+
+// resourceURI 由主机名和路径生成资源 URI，文件夹以 / 结尾
+func resourceURI(host, path string, isDir bool) string {
+	u := url.URL{Scheme: resourceScheme, Host: host, Path: resourceURIPath(path, isDir)}
+	return u.String()
+}
+
+// resourceURIPath 将文件系统路径转换为资源 URI 中的路径（未转义）
+// Windows 路径中的 \ 替换为 /，C:\Users 变为 /C:/Users，\\server\share 变为 ///server/share
+func resourceURIPath(path string, isDir bool) string {
+	p := strings.ReplaceAll(path, `\`, "/")
+	if !strings.HasPrefix(p, "/") || strings.HasPrefix(p, "//") {
+		p = "/" + p
+	}
+	if isDir && !strings.HasSuffix(p, "/") {
+		p += "/"
+	}
+	return p
+}
+
+// resourcePath 将资源 URI 中的路径还原为文件系统路径，并返回它是否表示文件夹
+// 以 / 结尾的路径和驱动器根目录（C:）表示文件夹
+func resourcePath(p string) (path string, isDir bool, err error) {
+	isDir = strings.HasSuffix(p, "/")
+	switch {
+	case len(p) >= 3 && p[0] == '/' && p[2] == ':' && isASCIILetter(p[1]):
+		// /C:/Users/me -> C:\Users\me
+		path = strings.ReplaceAll(p[1:], "/", `\`)
+		if len(path) <= 3 {
+			return path[:2] + `\`, true, nil
+		}
+	case strings.HasPrefix(p, "///"):
+		// ///server/share -> \\server\share
+		path = strings.ReplaceAll(p[1:], "/", `\`)
+	case strings.HasPrefix(p, "/") && len(p) > 1:
+		path = p
+	default:
+		return "", false, fmt.Errorf("缺少路径")
+	}
+	if trimmed := strings.TrimRight(path, `\/`); trimmed != "" {
+		path = trimmed
+	}
+	return path, isDir, nil
+}
+
+func isASCIILetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// handleReadResource 读取文件、文件夹或搜索结果资源
+// 内容包含文本形式（与对应工具的文本结果相同）和 JSON 形式（与工具的 structuredContent 相同）
+func (s *MCPEverythingServer) handleReadResource(ctx context.Context, uri string) (*mcp.ReadResourceResult, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != resourceScheme || u.Host == "" {
+		return nil, invalidParams("无效的资源 URI: %s", uri)
+	}
+
+	var result *CallToolResult
+	if u.Host == searchResourceHost {
+		result, err = s.readSearchResource(ctx, u)
+	} else {
+		result, err = s.readPathResource(ctx, u)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	text := toolResultText(result)
+	if result.IsError {
+		return nil, newRPCError(codeInternalError, "读取资源失败: %s", strings.TrimSpace(text))
+	}
+
+	contents := []mcp.ResourceContents{
+		mcp.TextResourceContents{URI: uri, MimeType: "text/plain", Text: text},
+	}
+	if result.StructuredContent != nil {
+		data, err := json.Marshal(result.StructuredContent)
+		if err != nil {
+			return nil, err
+		}
+		contents = append(contents, mcp.TextResourceContents{URI: uri, MimeType: "application/json", Text: string(data)})
+	}
+	return &mcp.ReadResourceResult{Contents: contents}, nil
+}
+
+// readSearchResource 读取 everything://search?q=... 资源，结果与 search_files 工具相同
+func (s *MCPEverythingServer) readSearchResource(ctx context.Context, u *url.URL) (*CallToolResult, error) {
+	if err := s.requireResourceTool(searchResourceTool, u.String()); err != nil {
+		return nil, err
+	}
+	values := u.Query()
+	query := values.Get("q")
+	if query == "" {
+		return nil, invalidParams("搜索资源缺少参数 q")
+	}
+
+	args := map[string]interface{}{"query": query}
+	if v := values.Get("max_results"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return nil, invalidParams("无效的 max_results: %s", v)
+		}
+		args["max_results"] = float64(n)
+	}
+	for _, name := range []string{"sort", "order"} {
+		if v := values.Get(name); v != "" {
+			args[name] = v
+		}
+	}
+	return s.handleSearchFiles(ctx, args)
+}
+
+// readPathResource 读取文件或文件夹资源：文件夹的结果与 list_directory 工具相同，文件与 get_file_info 相同
+func (s *MCPEverythingServer) readPathResource(ctx context.Context, u *url.URL) (*CallToolResult, error) {
+	view, err := s.forResourceHost(u.Host)
+	if err != nil {
+		return nil, err
+	}
+	path, isDir, err := resourcePath(u.Path)
+	if err != nil {
+		return nil, invalidParams("无效的资源 URI: %s: %v", u.String(), err)
+	}
+	if !s.policy.Allowed(path) {
+		return nil, invalidParams("访问策略不允许访问: %s", path)
+	}
+	if isDir || !s.tools[fileResourceTool] {
+		// 以 / 结尾的是文件夹；get_file_info 被禁用时只能读取文件夹
+		if err := s.requireResourceTool(folderResourceTool, u.String()); err != nil {
+			return nil, err
+		}
+	}
+
+	if !isDir {
+		entry, warnings, err := view.lookupPath(ctx, path)
+		if err != nil {
+			return nil, newRPCError(codeInternalError, "读取资源失败: %v", err)
+		}
+		if entry == nil {
+			return nil, newRPCError(codeResourceNotFound, "资源不存在: %s", u.String())
+		}
+		if entry.Type == "folder" {
+			if err := s.requireResourceTool(folderResourceTool, u.String()); err != nil {
+				return nil, err
+			}
+		} else {
+			if err := s.requireResourceTool(fileResourceTool, u.String()); err != nil {
+				return nil, err
+			}
+			return &CallToolResult{
+				Content: []mcp.Content{
+					mcp.TextContent{
+						Type: "text",
+						Text: view.fileInfoText(*entry) + formatWarnings(warnings),
+					},
+				},
+				StructuredContent: newFileEntry(*entry),
+			}, nil
+		}
+	}
+	return view.handleListDirectory(ctx, map[string]interface{}{"path": path})
+}
+
+// forResourceHost 返回读取指定主机资源时使用的服务器
+// localhost 使用所有后端；联合搜索时后端名称只查询该后端（仍然应用访问策略）
+func (s *MCPEverythingServer) forResourceHost(host string) (*MCPEverythingServer, error) {
+	if strings.EqualFold(host, localResourceHost) {
+		return s, nil
+	}
+	if s.federated != nil {
+		if backend, ok := s.federated.Backend(host); ok {
+			view := *s
+			view.client = backend
+			if s.policy != nil {
+				view.client = NewPolicySearcher(backend, s.policy)
+			}
+			return &view, nil
+		}
+	}
+	return nil, newRPCError(codeResourceNotFound, "未知的主机: %s（可选: %s）", host, strings.Join(s.resourceHosts(), ", "))
+}
+
+// lookupPath 精确查找一个文件或文件夹，不存在时返回 nil
+// Everything 的文本搜索是子串匹配，因此在父文件夹中按名称搜索后再比较完整路径；
+// 驱动器和本地后端的根目录不是任何文件夹的子项，按 root: 的结果查找
+func (s *MCPEverythingServer) lookupPath(ctx context.Context, path string) (*SearchResult, []string, error) {
+	if i := strings.LastIndexAny(path, `\/`); i >= 0 && i < len(path)-1 {
+		query, err := NewQuery(Parent(path[:i+1]), Text(path[i+1:])).Build()
+		if err != nil {
+			return nil, nil, err
+		}
+		response, err := s.client.Search(ctx, SearchOptions{Query: query, MaxResults: 100, Columns: fileInfoColumns})
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, result := range response.Results {
+			if samePath(result.Path, path) {
+				return &result, response.Warnings, nil
+			}
+		}
+	}
+
+	response, err := s.client.Search(ctx, SearchOptions{Query: NewQuery(Roots()).String(), MaxResults: 100})
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, result := range response.Results {
+		if samePath(result.Path, path) {
+			result.Type = "folder"
+			return &result, response.Warnings, nil
+		}
+	}
+	return nil, nil, nil
+}
+
+// samePath 比较两个路径是否相同：不区分大小写，忽略末尾的分隔符
+func samePath(a, b string) bool {
+	return strings.EqualFold(strings.TrimRight(a, `\/`), strings.TrimRight(b, `\/`))
+}
+
+// toolResultText 返回工具结果中的文本内容
+func toolResultText(result *CallToolResult) string {
+	if len(result.Content) > 0 {
+		if content, ok := result.Content[0].(mcp.TextContent); ok {
+			return content.Text
+		}
+	}
+	return ""
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+// 重试和熔断的默认参数
+const (
+	defaultRetries          = 2
+	defaultRetryBackoff     = 200 * time.Millisecond
+	maxRetryBackoff         = 5 * time.Second
+	defaultBreakerThreshold = 3
+	defaultBreakerProbe     = 15 * time.Second
+)
+
+// backendError 后端不可达或服务器端错误，计入熔断器的失败次数
+type backendError struct {
+	err error
+	// retry 是否值得重试（连接重置、5xx）；超时已经用完等待时间，不重试
+	retry bool
+}
+
+func (e *backendError) Error() string { return e.err.Error() }
+
+func (e *backendError) Unwrap() error { return e.err }
+
+// retryBackoff 返回第 attempt 次重试前的等待时间
+// 等待时间按 base * 2^attempt 指数增长（不超过 maxRetryBackoff），
+// 并在 [d/2, d] 之间随机抖动，避免多个请求同时重试
+func retryBackoff(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		base = defaultRetryBackoff
+	}
+	d := base << attempt
+	if d <= 0 || d > maxRetryBackoff {
+		d = maxRetryBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// get 发送 GET 请求，失败时按配置重试，并通过熔断器在后端不可用时快速失败
+func (c *EverythingClient) get(ctx context.Context, searchURL string) ([]byte, error) {
+	if err := c.breaker.allow(); err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		body, err := c.attempt(ctx, searchURL)
+		if err == nil {
+			c.breaker.success()
+			return body, nil
+		}
+		if ctx.Err() != nil {
+			// 调用方取消或超时，与后端是否可用无关
+			return nil, err
+		}
+
+		var backendErr *backendError
+		if !errors.As(err, &backendErr) {
+			// 后端有响应（例如 401），说明它是可用的
+			c.breaker.success()
+			return nil, err
+		}
+		if !backendErr.retry || attempt >= c.config.Retries {
+			c.breaker.failure(err)
+			return nil, err
+		}
+
+		wait := retryBackoff(c.config.RetryBackoff, attempt)
+		if os.Getenv("EVERYTHING_DEBUG") == "true" {
+			fmt.Fprintf(os.Stderr, "[DEBUG] 第 %d 次重试（%s 后）: %v\n", attempt+1, wait, err)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// circuitBreaker 熔断器：连续 threshold 次请求失败后进入断开状态，
+// 断开期间所有请求立即返回错误，同时在后台每隔 probeInterval 探测一次，探测成功后恢复
+type circuitBreaker struct {
+	threshold     int
+	probeInterval time.Duration
+	probe         func(ctx context.Context) error
+
+	mu        sync.Mutex
+	failures  int       // 连续失败次数
+	openSince time.Time // 进入断开状态的时间，零值表示闭合
+	lastErr   error
+
+	// stopped 关闭后停止后台探测
+	stopOnce sync.Once
+	stopped  chan struct{}
+}
+
+// newCircuitBreaker 创建熔断器，threshold <= 0 时不启用（返回 nil）
+func newCircuitBreaker(threshold int, probeInterval time.Duration, probe func(ctx context.Context) error) *circuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	if probeInterval <= 0 {
+		probeInterval = defaultBreakerProbe
+	}
+	return &circuitBreaker{threshold: threshold, probeInterval: probeInterval, probe: probe, stopped: make(chan struct{})}
+}
+
+// close 停止后台探测，例如重新加载配置后旧的客户端不再使用；可以重复调用，b 为 nil 时不做任何事
+func (b *circuitBreaker) close() {
+	if b == nil {
+		return
+	}
+	b.stopOnce.Do(func() { close(b.stopped) })
+}
+
+// allow 断开状态下返回说明后端不可用的错误
+func (b *circuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openSince.IsZero() {
+		return nil
+	}
+	return fmt.Errorf("Everything 后端自 %s 起不可用（最近的错误: %v），每 %s 自动探测一次",
+		b.openSince.Format("2006-01-02 15:04:05"), b.lastErr, b.probeInterval)
+}
+
+// success 记录一次成功，断开状态下恢复为闭合
+func (b *circuitBreaker) success() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.openSince.IsZero() && os.Getenv("EVERYTHING_DEBUG") == "true" {
+		fmt.Fprintf(os.Stderr, "[DEBUG] Everything 后端已恢复（自 %s 起不可用）\n", b.openSince.Format("2006-01-02 15:04:05"))
+	}
+	b.failures = 0
+	b.openSince = time.Time{}
+	b.lastErr = nil
+}
+
+// failure 记录一次失败，达到阈值时断开并开始后台探测
+func (b *circuitBreaker) failure(err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.lastErr = err
+	if b.openSince.IsZero() && b.failures >= b.threshold {
+		b.openSince = time.Now()
+		if os.Getenv("EVERYTHING_DEBUG") == "true" {
+			fmt.Fprintf(os.Stderr, "[DEBUG] Everything 后端连续 %d 次失败，熔断: %v\n", b.failures, err)
+		}
+		go b.probeLoop()
+	}
+}
+
+// probeLoop 断开期间定期探测后端，成功后或熔断器关闭后结束
+func (b *circuitBreaker) probeLoop() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-b.stopped:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ticker := time.NewTicker(b.probeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		b.mu.Lock()
+		open := !b.openSince.IsZero()
+		b.mu.Unlock()
+		if !open {
+			return
+		}
+
+		err := b.probe(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			b.success()
+			return
+		}
+		b.mu.Lock()
+		b.lastErr = err
+		b.mu.Unlock()
+	}
+}
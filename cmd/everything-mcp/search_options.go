@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultColumns 未指定 Columns 时请求的结果列（路径列总是会请求）
+var defaultColumns = []string{"size", "date_modified"}
+
+// fileInfoColumns get_file_info 请求的全部结果列
+var fileInfoColumns = []string{"size", "date_modified", "date_created", "date_accessed", "date_run", "attributes"}
+
+// SearchOptions 一次搜索的参数，对应 Everything HTTP API 的查询参数
+type SearchOptions struct {
+	// Query Everything 搜索语法的查询字符串
+	Query string
+	// Offset 跳过的结果数，用于分页
+	Offset int
+	// MaxResults 最大返回结果数，<= 0 表示不限制
+	MaxResults int
+	// Sort 排序方式，为空时使用 Everything 的默认排序
+	Sort SearchSort
+
+	// MatchCase 区分大小写
+	MatchCase bool
+	// MatchWholeWord 全字匹配
+	MatchWholeWord bool
+	// MatchPath 匹配完整路径而不仅是文件名
+	MatchPath bool
+	// MatchDiacritics 区分变音符号
+	MatchDiacritics bool
+	// Regex 将 Query 作为正则表达式处理（而不是 Everything 搜索语法）
+	Regex bool
+
+	// Columns 需要返回的结果列，例如 "size"、"date_modified"；为空时使用 defaultColumns
+	Columns []string
+}
+
+// SearchSort 搜索结果的排序方式，对应 Everything HTTP API 的 sort 和 ascending 参数
+type SearchSort struct {
+	// Field 排序字段: name, path, size, date_modified；为空时使用 Everything 的默认排序（名称升序）
+	Field string
+	// Ascending 是否升序
+	Ascending bool
+}
+
+// sortFields 支持的排序字段
+var sortFields = []string{"name", "path", "size", "date_modified"}
+
+// String 返回排序方式的文本表示，例如 "size desc"
+func (o SearchSort) String() string {
+	if o.Field == "" {
+		return ""
+	}
+	if o.Ascending {
+		return o.Field + " asc"
+	}
+	return o.Field + " desc"
+}
+
+// defaultAscending 未指定 order 时的默认方向：大小和日期降序（最大、最新的优先），名称和路径升序
+func defaultAscending(field string) bool {
+	return field != "size" && field != "date_modified"
+}
+
+// parseSort 从工具参数中解析 sort 和 order，未指定 sort 时使用 defaultSort
+func parseSort(args map[string]interface{}, defaultSort SearchSort) (SearchSort, error) {
+	result := defaultSort
+
+	if field, ok := args["sort"].(string); ok && field != "" {
+		field = strings.ToLower(field)
+		valid := false
+		for _, f := range sortFields {
+			if f == field {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return SearchSort{}, fmt.Errorf("不支持的排序字段: %s（可选: %s）", field, strings.Join(sortFields, ", "))
+		}
+		result = SearchSort{Field: field, Ascending: defaultAscending(field)}
+	}
+
+	if order, ok := args["order"].(string); ok && order != "" {
+		if result.Field == "" {
+			// 只指定了方向时按名称排序
+			result.Field = "name"
+		}
+		switch strings.ToLower(order) {
+		case "asc":
+			result.Ascending = true
+		case "desc":
+			result.Ascending = false
+		default:
+			return SearchSort{}, fmt.Errorf("不支持的排序方向: %s（可选: asc, desc）", order)
+		}
+	}
+
+	return result, nil
+}
+
+// applyMatchOptions 将工具参数中的匹配选项（match_case 等）应用到搜索参数
+func applyMatchOptions(args map[string]interface{}, opts *SearchOptions) {
+	if v, ok := args["match_case"].(bool); ok {
+		opts.MatchCase = v
+	}
+	if v, ok := args["match_whole_word"].(bool); ok {
+		opts.MatchWholeWord = v
+	}
+	if v, ok := args["match_path"].(bool); ok {
+		opts.MatchPath = v
+	}
+	if v, ok := args["match_diacritics"].(bool); ok {
+		opts.MatchDiacritics = v
+	}
+}
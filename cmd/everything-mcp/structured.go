@@ -0,0 +1,332 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Tool MCP 工具定义，在 mcp.Tool 的基础上增加 outputSchema
+type Tool struct {
+	Name         string                 `json:"name"`
+	Description  string                 `json:"description,omitempty"`
+	InputSchema  mcp.ToolInputSchema    `json:"inputSchema"`
+	OutputSchema map[string]interface{} `json:"outputSchema,omitempty"`
+}
+
+// ListToolsResult tools/list 的结果
+type ListToolsResult struct {
+	Meta       *mcp.MetaData `json:"_meta,omitempty"`
+	NextCursor string        `json:"nextCursor,omitempty"`
+	Tools      []Tool        `json:"tools"`
+}
+
+// CallToolResult tools/call 的结果，在 mcp.CallToolResult 的基础上增加 structuredContent
+// 结构化内容与 outputSchema 对应，文本内容保留给不支持结构化结果的客户端
+type CallToolResult struct {
+	Meta              *mcp.MetaData `json:"_meta,omitempty"`
+	Content           []mcp.Content `json:"content"`
+	StructuredContent interface{}   `json:"structuredContent,omitempty"`
+	IsError           bool          `json:"isError,omitempty"`
+}
+
+// FileEntry 结构化结果中的一个文件或文件夹
+type FileEntry struct {
+	Path         string `json:"path"`
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+	Size         *int64 `json:"size,omitempty"`
+	DateModified string `json:"date_modified,omitempty"`
+	Level        int    `json:"level,omitempty"`
+	Source       string `json:"source,omitempty"`
+
+	// 以下字段只在 get_file_info 中提供
+	DateCreated  string               `json:"date_created,omitempty"`
+	DateAccessed string               `json:"date_accessed,omitempty"`
+	DateRun      string               `json:"date_run,omitempty"`
+	Attributes   *FileEntryAttributes `json:"attributes,omitempty"`
+}
+
+// FileEntryAttributes 结构化结果中的文件属性
+type FileEntryAttributes struct {
+	ReadOnly     bool `json:"readonly"`
+	Hidden       bool `json:"hidden"`
+	System       bool `json:"system"`
+	ReparsePoint bool `json:"reparse_point"`
+	// Value 原始的 FILE_ATTRIBUTE_* 位
+	Value uint32 `json:"value"`
+}
+
+// SearchOutput 搜索类工具的结构化结果
+type SearchOutput struct {
+	Query      string      `json:"query"`
+	Sort       string      `json:"sort,omitempty"`
+	Total      int         `json:"total"`
+	Offset     int         `json:"offset"`
+	Count      int         `json:"count"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	Results    []FileEntry `json:"results"`
+	Warnings   []string    `json:"warnings,omitempty"`
+	Redacted   int         `json:"redacted,omitempty"`
+}
+
+// DirectoryOutput list_directory 的结构化结果
+type DirectoryOutput struct {
+	Path     string      `json:"path"`
+	Depth    int         `json:"depth"`
+	Folders  int         `json:"folders"`
+	Files    int         `json:"files"`
+	Results  []FileEntry `json:"results"`
+	Warnings []string    `json:"warnings,omitempty"`
+	Redacted int         `json:"redacted,omitempty"`
+}
+
+// DrivesOutput list_drives 的结构化结果
+type DrivesOutput struct {
+	Count    int      `json:"count"`
+	Drives   []string `json:"drives"`
+	Sources  []string `json:"sources,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+	Redacted int      `json:"redacted,omitempty"`
+}
+
+// newFileEntry 将搜索结果转换为结构化条目
+// 大小保留原始字节数（文件夹没有大小），时间转换为 UTC 的 RFC 3339 格式
+func newFileEntry(result SearchResult) FileEntry {
+	entry := FileEntry{
+		Path:   result.Path,
+		Name:   result.Path,
+		Type:   result.Type,
+		Source: result.Source,
+	}
+	if i := strings.LastIndexAny(result.Path, "\\/"); i >= 0 && i < len(result.Path)-1 {
+		entry.Name = result.Path[i+1:]
+	}
+	if result.Type != "folder" {
+		size := result.Size
+		entry.Size = &size
+	}
+	entry.DateModified = structuredTime(result.DateModified)
+	entry.DateCreated = structuredTime(result.DateCreated)
+	entry.DateAccessed = structuredTime(result.DateAccessed)
+	entry.DateRun = structuredTime(result.DateRun)
+	if attrs := result.Attributes; attrs != 0 {
+		entry.Attributes = &FileEntryAttributes{
+			ReadOnly:     attrs.ReadOnly(),
+			Hidden:       attrs.Hidden(),
+			System:       attrs.System(),
+			ReparsePoint: attrs.ReparsePoint(),
+			Value:        uint32(attrs),
+		}
+	}
+	return entry
+}
+
+// newSearchOutput 构建搜索类工具的结构化结果，最多包含 opts.MaxResults 个条目
+func newSearchOutput(opts SearchOptions, response *SearchResponse) SearchOutput {
+	output := SearchOutput{
+		Query:    opts.Query,
+		Sort:     opts.Sort.String(),
+		Total:    response.TotalResults,
+		Offset:   opts.Offset,
+		Results:  make([]FileEntry, 0, len(response.Results)),
+		Warnings: response.Warnings,
+		Redacted: response.Redacted,
+	}
+	for i, result := range response.Results {
+		if opts.MaxResults > 0 && i >= opts.MaxResults {
+			break
+		}
+		output.Results = append(output.Results, newFileEntry(result))
+	}
+	output.Count = len(output.Results)
+	output.NextCursor = nextCursor(opts, output.Count+response.Redacted, output.Total)
+	return output
+}
+
+// fileEntrySchema FileEntry 的 JSON Schema
+var fileEntrySchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"path": map[string]interface{}{
+			"type":        "string",
+			"description": "完整路径",
+		},
+		"name": map[string]interface{}{
+			"type":        "string",
+			"description": "文件或文件夹名称",
+		},
+		"type": map[string]interface{}{
+			"type":        "string",
+			"description": "类型: file 或 folder",
+		},
+		"size": map[string]interface{}{
+			"type":        "integer",
+			"description": "文件大小（字节），文件夹没有该字段",
+		},
+		"date_modified": map[string]interface{}{
+			"type":        "string",
+			"format":      "date-time",
+			"description": "修改时间（UTC，RFC 3339）",
+		},
+		"level": map[string]interface{}{
+			"type":        "integer",
+			"description": "仅 list_directory: 相对于浏览目录的层级，直接子项为 0",
+		},
+		"source": map[string]interface{}{
+			"type":        "string",
+			"description": "联合搜索时结果所属的后端名称",
+		},
+		"date_created": map[string]interface{}{
+			"type":        "string",
+			"format":      "date-time",
+			"description": "仅 get_file_info: 创建时间（UTC，RFC 3339）",
+		},
+		"date_accessed": map[string]interface{}{
+			"type":        "string",
+			"format":      "date-time",
+			"description": "仅 get_file_info: 访问时间（UTC，RFC 3339）",
+		},
+		"date_run": map[string]interface{}{
+			"type":        "string",
+			"format":      "date-time",
+			"description": "仅 get_file_info: 最近一次通过 Everything 打开的时间（UTC，RFC 3339）",
+		},
+		"attributes": map[string]interface{}{
+			"type":        "object",
+			"description": "仅 get_file_info: 文件属性",
+			"properties": map[string]interface{}{
+				"readonly":      map[string]interface{}{"type": "boolean"},
+				"hidden":        map[string]interface{}{"type": "boolean"},
+				"system":        map[string]interface{}{"type": "boolean"},
+				"reparse_point": map[string]interface{}{"type": "boolean", "description": "符号链接、目录联接等"},
+				"value":         map[string]interface{}{"type": "integer", "description": "原始的 FILE_ATTRIBUTE_* 位"},
+			},
+		},
+	},
+	"required": []string{"path", "name", "type"},
+}
+
+// searchOutputSchema 搜索类工具的 outputSchema
+var searchOutputSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"query": map[string]interface{}{
+			"type":        "string",
+			"description": "发送给 Everything 的搜索查询",
+		},
+		"sort": map[string]interface{}{
+			"type":        "string",
+			"description": "排序方式，例如 \"size desc\"；未排序时不返回",
+		},
+		"total": map[string]interface{}{
+			"type":        "integer",
+			"description": "匹配的结果总数",
+		},
+		"offset": map[string]interface{}{
+			"type":        "integer",
+			"description": "本页第一个结果的位置（从 0 开始）",
+		},
+		"count": map[string]interface{}{
+			"type":        "integer",
+			"description": "本次返回的结果数量",
+		},
+		"next_cursor": map[string]interface{}{
+			"type":        "string",
+			"description": "下一页的游标，作为 cursor 参数传回；没有更多结果时不返回",
+		},
+		"results": map[string]interface{}{
+			"type":  "array",
+			"items": fileEntrySchema,
+		},
+		"warnings": map[string]interface{}{
+			"type":        "array",
+			"description": "部分结果警告，例如联合搜索中失败或超时的后端",
+			"items": map[string]interface{}{
+				"type": "string",
+			},
+		},
+		"redacted": map[string]interface{}{
+			"type":        "integer",
+			"description": "被访问策略隐藏的结果数",
+		},
+	},
+	"required": []string{"query", "total", "offset", "count", "results"},
+}
+
+// directoryOutputSchema list_directory 的 outputSchema
+var directoryOutputSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"path": map[string]interface{}{
+			"type":        "string",
+			"description": "浏览的目录路径",
+		},
+		"depth": map[string]interface{}{
+			"type":        "integer",
+			"description": "浏览深度",
+		},
+		"folders": map[string]interface{}{
+			"type":        "integer",
+			"description": "文件夹数量",
+		},
+		"files": map[string]interface{}{
+			"type":        "integer",
+			"description": "文件数量",
+		},
+		"results": map[string]interface{}{
+			"type":        "array",
+			"description": "目录条目，level 表示相对于 path 的层级（直接子项为 0）",
+			"items":       fileEntrySchema,
+		},
+		"warnings": map[string]interface{}{
+			"type":        "array",
+			"description": "部分结果警告，例如联合搜索中失败或超时的后端",
+			"items": map[string]interface{}{
+				"type": "string",
+			},
+		},
+		"redacted": map[string]interface{}{
+			"type":        "integer",
+			"description": "被访问策略隐藏的结果数",
+		},
+	},
+	"required": []string{"path", "depth", "folders", "files", "results"},
+}
+
+// drivesOutputSchema list_drives 的 outputSchema
+var drivesOutputSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"count": map[string]interface{}{
+			"type":        "integer",
+			"description": "驱动器数量",
+		},
+		"drives": map[string]interface{}{
+			"type":        "array",
+			"description": "驱动器根目录，例如 C:\\",
+			"items": map[string]interface{}{
+				"type": "string",
+			},
+		},
+		"sources": map[string]interface{}{
+			"type":        "array",
+			"description": "联合搜索时每个驱动器所属的后端名称，与 drives 一一对应",
+			"items": map[string]interface{}{
+				"type": "string",
+			},
+		},
+		"warnings": map[string]interface{}{
+			"type":        "array",
+			"description": "部分结果警告，例如联合搜索中失败或超时的后端",
+			"items": map[string]interface{}{
+				"type": "string",
+			},
+		},
+		"redacted": map[string]interface{}{
+			"type":        "integer",
+			"description": "被访问策略隐藏的结果数",
+		},
+	},
+	"required": []string{"count", "drives"},
+}
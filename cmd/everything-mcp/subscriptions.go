@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultResourcePollInterval 订阅的搜索结果资源默认的轮询间隔
+const defaultResourcePollInterval = 10 * time.Second
+
+// subscriber 一个客户端会话的资源订阅身份，资源更新通知通过 send 发送
+// 会话结束时调用 close，轮询时会去掉已关闭的订阅者
+type subscriber struct {
+	send func(msg []byte)
+
+	once sync.Once
+	done chan struct{}
+}
+
+// newSubscriber 创建订阅者
+func newSubscriber(send func(msg []byte)) *subscriber {
+	return &subscriber{send: send, done: make(chan struct{})}
+}
+
+// close 结束会话，取消该会话的所有订阅；可以重复调用，s 为 nil 时不做任何事
+func (s *subscriber) close() {
+	if s == nil {
+		return
+	}
+	s.once.Do(func() { close(s.done) })
+}
+
+// closed 判断会话是否已结束
+func (s *subscriber) closed() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// subscriberKey 在 context 中保存当前会话订阅者的键
+type subscriberKey struct{}
+
+// withSubscriber 在 context 中记录当前会话的订阅者，sub 为 nil 时不做任何事
+func withSubscriber(ctx context.Context, sub *subscriber) context.Context {
+	if sub == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, subscriberKey{}, sub)
+}
+
+// subscriberFromContext 返回当前会话的订阅者，没有时返回 nil
+func subscriberFromContext(ctx context.Context) *subscriber {
+	sub, _ := ctx.Value(subscriberKey{}).(*subscriber)
+	return sub
+}
+
+// resourceSnapshot 一次轮询得到的搜索结果，按 snapshotKey 索引
+type resourceSnapshot map[string]FileEntry
+
+// snapshotKey 返回结果在快照中的键；联合搜索时不同后端可能返回相同的路径，
+// 所以带上来源，格式与结果文本中的 "[来源] 路径" 一致
+func snapshotKey(entry FileEntry) string {
+	if entry.Source == "" {
+		return entry.Path
+	}
+	return fmt.Sprintf("[%s] %s", entry.Source, entry.Path)
+}
+
+// resourceDelta 两次轮询之间搜索结果的变化
+type resourceDelta struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	// Changed 大小或修改时间变化了的结果
+	Changed []string `json:"changed,omitempty"`
+}
+
+// empty 判断是否没有变化
+func (d resourceDelta) empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// summary 变化的文字摘要
+func (d resourceDelta) summary() string {
+	return fmt.Sprintf("新增 %d 个，删除 %d 个，变化 %d 个", len(d.Added), len(d.Removed), len(d.Changed))
+}
+
+// diffSnapshots 比较两次轮询的结果，路径按字母排序
+func diffSnapshots(prev, next resourceSnapshot) resourceDelta {
+	var delta resourceDelta
+	for key, entry := range next {
+		old, ok := prev[key]
+		switch {
+		case !ok:
+			delta.Added = append(delta.Added, key)
+		case !sameSize(old.Size, entry.Size) || old.DateModified != entry.DateModified:
+			delta.Changed = append(delta.Changed, key)
+		}
+	}
+	for key := range prev {
+		if _, ok := next[key]; !ok {
+			delta.Removed = append(delta.Removed, key)
+		}
+	}
+	sort.Strings(delta.Added)
+	sort.Strings(delta.Removed)
+	sort.Strings(delta.Changed)
+	return delta
+}
+
+func sameSize(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// resourceUpdatedNotification 创建 notifications/resources/updated 通知，params 中附带变化的摘要和路径
+func resourceUpdatedNotification(uri string, delta resourceDelta) []byte {
+	notification, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  "notifications/resources/updated",
+		"params": struct {
+			URI     string `json:"uri"`
+			Summary string `json:"summary"`
+			resourceDelta
+		}{uri, delta.summary(), delta},
+	})
+	return notification
+}
+
+// searchSnapshot 读取搜索结果资源，返回当前的结果
+// 轮询不经过缓存，否则在缓存有效期内看不到变化
+func (s *MCPEverythingServer) searchSnapshot(ctx context.Context, uri string) (resourceSnapshot, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != resourceScheme || u.Host != searchResourceHost {
+		return nil, invalidParams("只能订阅搜索结果资源（everything://search?q=...）: %s", uri)
+	}
+
+	view := s
+	if s.cache != nil {
+		uncached := *s
+		uncached.client = s.cache.next
+		if s.policy != nil {
+			uncached.client = NewPolicySearcher(s.cache.next, s.policy)
+		}
+		view = &uncached
+	}
+
+	result, err := view.readSearchResource(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	output, ok := result.StructuredContent.(SearchOutput)
+	if result.IsError || !ok {
+		return nil, newRPCError(codeInternalError, "读取资源失败: %s", strings.TrimSpace(toolResultText(result)))
+	}
+	snapshot := make(resourceSnapshot, len(output.Results))
+	for _, entry := range output.Results {
+		snapshot[snapshotKey(entry)] = entry
+	}
+	return snapshot, nil
+}
+
+// resourceSubscriptions 管理所有会话对搜索结果资源的订阅
+// 同一个 URI 只轮询一次，变化通知发送给订阅了它的所有会话；
+// 重新加载配置后继续使用新的配置（后端、访问策略和轮询间隔）轮询
+type resourceSubscriptions struct {
+	// current 返回当前生效的服务器
+	current func() *MCPEverythingServer
+
+	mu      sync.Mutex
+	watches map[string]*resourceWatch
+}
+
+// resourceWatch 一个被订阅的资源
+type resourceWatch struct {
+	uri         string
+	subscribers map[*subscriber]struct{}
+	cancel      context.CancelFunc
+}
+
+// newResourceSubscriptions 创建订阅管理器
+func newResourceSubscriptions(current func() *MCPEverythingServer) *resourceSubscriptions {
+	return &resourceSubscriptions{current: current, watches: make(map[string]*resourceWatch)}
+}
+
+// subscribe 为会话订阅资源；第一个订阅者会先读取一次资源作为比较的基准，资源无效时返回错误
+func (rs *resourceSubscriptions) subscribe(ctx context.Context, sub *subscriber, uri string) error {
+	rs.mu.Lock()
+	if w, ok := rs.watches[uri]; ok {
+		w.subscribers[sub] = struct{}{}
+		rs.mu.Unlock()
+		return nil
+	}
+	rs.mu.Unlock()
+
+	snapshot, err := rs.current().searchSnapshot(ctx, uri)
+	if err != nil {
+		return err
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	// 读取期间其他会话可能已经订阅了同一个资源
+	if w, ok := rs.watches[uri]; ok {
+		w.subscribers[sub] = struct{}{}
+		return nil
+	}
+	pollCtx, cancel := context.WithCancel(context.Background())
+	w := &resourceWatch{
+		uri:         uri,
+		subscribers: map[*subscriber]struct{}{sub: {}},
+		cancel:      cancel,
+	}
+	rs.watches[uri] = w
+	go rs.poll(pollCtx, w, snapshot)
+	return nil
+}
+
+// unsubscribe 取消会话对资源的订阅，没有订阅者时停止轮询
+func (rs *resourceSubscriptions) unsubscribe(sub *subscriber, uri string) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	w, ok := rs.watches[uri]
+	if !ok {
+		return
+	}
+	delete(w.subscribers, sub)
+	if len(w.subscribers) == 0 {
+		w.cancel()
+		delete(rs.watches, uri)
+	}
+}
+
+// liveSubscribers 去掉已结束的会话，返回仍然订阅资源的会话；没有订阅者时停止轮询
+func (rs *resourceSubscriptions) liveSubscribers(w *resourceWatch) []*subscriber {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	subs := make([]*subscriber, 0, len(w.subscribers))
+	for sub := range w.subscribers {
+		if sub.closed() {
+			delete(w.subscribers, sub)
+			continue
+		}
+		subs = append(subs, sub)
+	}
+	if len(subs) == 0 {
+		w.cancel()
+		if rs.watches[w.uri] == w {
+			delete(rs.watches, w.uri)
+		}
+	}
+	return subs
+}
+
+// poll 按轮询间隔读取资源，结果变化时通知订阅者，直到没有订阅者
+// 读取失败时保留上一次的结果，下一次再比较
+func (rs *resourceSubscriptions) poll(ctx context.Context, w *resourceWatch, last resourceSnapshot) {
+	for {
+		interval := rs.current().config.ResourcePollInterval
+		if interval <= 0 {
+			interval = defaultResourcePollInterval
+		}
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if len(rs.liveSubscribers(w)) == 0 {
+			return
+		}
+		snapshot, err := rs.current().searchSnapshot(ctx, w.uri)
+		if err != nil {
+			if ctx.Err() == nil && os.Getenv("EVERYTHING_DEBUG") == "true" {
+				fmt.Fprintf(os.Stderr, "[DEBUG] 轮询资源 %s 失败: %v\n", w.uri, err)
+			}
+			continue
+		}
+		delta := diffSnapshots(last, snapshot)
+		last = snapshot
+		if delta.empty() {
+			continue
+		}
+
+		notification := resourceUpdatedNotification(w.uri, delta)
+		for _, sub := range rs.liveSubscribers(w) {
+			sub.send(notification)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffSnapshotsKeepsSources(t *testing.T) {
+	size := func(n int64) *int64 { return &n }
+	snapshot := func(entries ...FileEntry) resourceSnapshot {
+		s := make(resourceSnapshot)
+		for _, entry := range entries {
+			s[snapshotKey(entry)] = entry
+		}
+		return s
+	}
+
+	// 两个后端返回相同的路径，只有 office 上的文件变化
+	prev := snapshot(
+		FileEntry{Path: `C:\a.log`, Source: "office", Size: size(1)},
+		FileEntry{Path: `C:\a.log`, Source: "home", Size: size(1)},
+		FileEntry{Path: `C:\b.log`, Source: "home"},
+	)
+	next := snapshot(
+		FileEntry{Path: `C:\a.log`, Source: "office", Size: size(2)},
+		FileEntry{Path: `C:\a.log`, Source: "home", Size: size(1)},
+		FileEntry{Path: `C:\b.log`, Source: "office"},
+	)
+
+	got := diffSnapshots(prev, next)
+	want := resourceDelta{
+		Added:   []string{`[office] C:\b.log`},
+		Removed: []string{`[home] C:\b.log`},
+		Changed: []string{`[office] C:\a.log`},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffSnapshots = %+v，期望 %+v", got, want)
+	}
+}
+
+func TestSnapshotKeyWithoutSource(t *testing.T) {
+	if got := snapshotKey(FileEntry{Path: "/srv/a.log"}); got != "/srv/a.log" {
+		t.Errorf("snapshotKey = %s，期望 /srv/a.log", got)
+	}
+}
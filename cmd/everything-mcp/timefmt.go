@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	// 内置时区数据库：Windows 上通常没有 zoneinfo，--timezone 也需要能用
+	_ "time/tzdata"
+)
+
+// displayTimeLayout 文本结果中的时间格式，带 UTC 偏移，避免服务器与 agent 时区不同时产生歧义
+const displayTimeLayout = "2006-01-02 15:04:05 -07:00"
+
+// loadDisplayLocation 解析显示时区，为空或 Local 时使用服务器本地时区
+func loadDisplayLocation(name string) (*time.Location, error) {
+	if name == "" || strings.EqualFold(name, "local") {
+		return time.Local, nil
+	}
+	if strings.EqualFold(name, "utc") {
+		return time.UTC, nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("无效的时区 %q: %w", name, err)
+	}
+	return location, nil
+}
+
+// displayTime 将 UTC 时间转换为显示时区的文本，精确到秒
+func (s *MCPEverythingServer) displayTime(t time.Time) string {
+	return t.In(s.location).Format(displayTimeLayout)
+}
+
+// structuredTime 结构化结果中的时间：UTC 的 RFC 3339 格式（保留小数秒），零值表示未知，返回空字符串
+func structuredTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
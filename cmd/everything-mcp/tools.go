@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// toolGroups 可以在 tools 和 disabled_tools 中代替工具名称使用的分组
+var toolGroups = map[string][]string{
+	"search": {
+		"search_files",
+		"search_by_extension",
+		"search_by_path",
+		"search_by_size",
+		"search_by_date",
+		"search_recent_files",
+		"search_large_files",
+		"search_empty_files",
+		"search_by_content_type",
+		"search_with_regex",
+		"search_duplicate_names",
+	},
+	"browse": {
+		"list_drives",
+		"list_directory",
+		"get_file_info",
+	},
+	"cache": {
+		"cache_stats",
+	},
+}
+
+// ToolLimits 单个工具的 max_results 设置，0 表示使用全局的 max_results 和 max_results_limit
+type ToolLimits struct {
+	MaxResults      int
+	MaxResultsLimit int
+}
+
+// toolSet 启用的工具名称
+type toolSet map[string]bool
+
+// isKnownTool 判断 name 是否为本服务器提供的工具
+func isKnownTool(name string) bool {
+	for _, names := range toolGroups {
+		for _, n := range names {
+			if n == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// expandToolNames 将工具名称和分组展开为工具名称，all 表示全部工具
+func expandToolNames(names []string) ([]string, error) {
+	var expanded []string
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		switch {
+		case name == "":
+		case name == "all":
+			for _, group := range toolGroups {
+				expanded = append(expanded, group...)
+			}
+		case toolGroups[name] != nil:
+			expanded = append(expanded, toolGroups[name]...)
+		case isKnownTool(name):
+			expanded = append(expanded, name)
+		default:
+			return nil, fmt.Errorf("未知的工具或分组: %s（分组: all, %s）", name, strings.Join(toolGroupNames(), ", "))
+		}
+	}
+	return expanded, nil
+}
+
+// toolGroupNames 返回分组名称，按字母排序
+func toolGroupNames() []string {
+	names := make([]string, 0, len(toolGroups))
+	for name := range toolGroups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newToolSet 根据配置计算启用的工具：enabled 为空时启用全部工具，再去掉 disabled 中的工具
+func newToolSet(config *EverythingConfig) (toolSet, error) {
+	enabled := config.Tools
+	if len(enabled) == 0 {
+		enabled = []string{"all"}
+	}
+	names, err := expandToolNames(enabled)
+	if err != nil {
+		return nil, fmt.Errorf("tools: %w", err)
+	}
+	disabled, err := expandToolNames(config.DisabledTools)
+	if err != nil {
+		return nil, fmt.Errorf("disabled_tools: %w", err)
+	}
+
+	tools := make(toolSet, len(names))
+	for _, name := range names {
+		tools[name] = true
+	}
+	for _, name := range disabled {
+		delete(tools, name)
+	}
+
+	for name := range config.ToolLimits {
+		if !isKnownTool(name) {
+			return nil, fmt.Errorf("tool_limits: 未知的工具: %s", name)
+		}
+	}
+	return tools, nil
+}
+
+// toolLimits 返回工具生效的 max_results 默认值和上限（<= 0 表示不限制）
+func (s *MCPEverythingServer) toolLimits(name string) (maxResults, limit int) {
+	maxResults, limit = s.config.MaxResults, s.config.MaxResultsLimit
+	if l, ok := s.config.ToolLimits[name]; ok {
+		if l.MaxResults > 0 {
+			maxResults = l.MaxResults
+		}
+		if l.MaxResultsLimit > 0 {
+			limit = l.MaxResultsLimit
+		}
+	}
+	if limit > 0 && maxResults > limit {
+		maxResults = limit
+	}
+	return maxResults, limit
+}
+
+// maxResults 读取 max_results 参数，未指定时使用工具的默认值，并限制在工具的上限以内
+func (s *MCPEverythingServer) maxResults(name string, args map[string]interface{}) int {
+	maxResults, limit := s.toolLimits(name)
+	if mr, ok := args["max_results"].(float64); ok {
+		maxResults = int(mr)
+	}
+	if limit > 0 && (maxResults <= 0 || maxResults > limit) {
+		maxResults = limit
+	}
+	return maxResults
+}
+
+// enabledTools 去掉被禁用的工具，并在 max_results 的说明中写明生效的默认值和上限
+func (s *MCPEverythingServer) enabledTools(tools []Tool) []Tool {
+	enabled := tools[:0]
+	for _, tool := range tools {
+		if !s.tools[tool.Name] {
+			continue
+		}
+		if prop, ok := tool.InputSchema.Properties["max_results"].(map[string]interface{}); ok {
+			maxResults, limit := s.toolLimits(tool.Name)
+			description := fmt.Sprintf("最大返回结果数量，默认 %d", maxResults)
+			if limit > 0 {
+				description += fmt.Sprintf("，最多 %d", limit)
+			}
+			prop["description"] = description
+			prop["default"] = maxResults
+		}
+		enabled = append(enabled, tool)
+	}
+	return enabled
+}
@@ -4,4 +4,8 @@ go 1.23.2
 
 toolchain go1.24.1
 
-require github.com/mark3labs/mcp-go v0.1.0
+require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/mark3labs/mcp-go v0.1.0
+	gopkg.in/yaml.v3 v3.0.1
+)